@@ -0,0 +1,102 @@
+/*
+Copyright AppsCode Inc. and Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmds
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/chartutil"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	requirementsFile     = "requirements.yaml"
+	requirementsLockFile = "requirements.lock"
+)
+
+const chartLockFile = "Chart.lock"
+
+// upgradeChartAPIVersion migrates an apiVersion v1 chart and its subcharts to apiVersion v2.
+// The Helm loader already reads requirements.yaml into the chart metadata and requirements.lock
+// into the chart lock, so the legacy files only need to be dropped and the lock digest
+// recomputed for the new Chart.yaml. The full paths of the upgraded charts are returned.
+func upgradeChartAPIVersion(ch *chart.Chart) ([]string, error) {
+	var upgraded []string
+	if ch.Metadata.APIVersion == chart.APIVersionV1 {
+		ch.Metadata.APIVersion = chart.APIVersionV2
+		files := make([]*chart.File, 0, len(ch.Files))
+		for _, f := range ch.Files {
+			if f.Name != requirementsFile && f.Name != requirementsLockFile {
+				files = append(files, f)
+			}
+		}
+		ch.Files = files
+		if ch.Lock != nil {
+			digest, err := hashReq(ch.Metadata.Dependencies, ch.Lock.Dependencies)
+			if err != nil {
+				return nil, err
+			}
+			ch.Lock.Digest = digest
+		}
+		upgraded = append(upgraded, ch.ChartFullPath())
+	}
+	for _, dep := range ch.Dependencies() {
+		names, err := upgradeChartAPIVersion(dep)
+		if err != nil {
+			return nil, err
+		}
+		upgraded = append(upgraded, names...)
+	}
+	return upgraded, nil
+}
+
+// saveChart saves ch into dest like chartutil.SaveDir, along with its Chart.lock.
+func saveChart(ch *chart.Chart, dest string) error {
+	if err := chartutil.SaveDir(ch, dest); err != nil {
+		return err
+	}
+	return saveChartLock(ch, dest)
+}
+
+// saveChartLock writes the Chart.lock of an apiVersion v2 chart saved by chartutil.SaveDir into dest,
+// since SaveDir itself does not write it. The requirements.lock of an apiVersion v1 chart is one of
+// its files, which SaveDir writes.
+func saveChartLock(ch *chart.Chart, dest string) error {
+	if ch.Metadata.APIVersion != chart.APIVersionV2 || ch.Lock == nil {
+		return nil
+	}
+	data, err := yaml.Marshal(ch.Lock)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dest, ch.Name(), chartLockFile), data, 0o644)
+}
+
+// hashReq computes the Chart.lock digest the same way `helm dependency` does.
+func hashReq(req, lock []*chart.Dependency) (string, error) {
+	data, err := json.Marshal([2][]*chart.Dependency{req, lock})
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return "sha256:" + hex.EncodeToString(sum[:]), nil
+}
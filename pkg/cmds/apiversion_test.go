@@ -0,0 +1,56 @@
+/*
+Copyright AppsCode Inc. and Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmds
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/chart/loader"
+)
+
+func TestSaveChartWritesLock(t *testing.T) {
+	dir := t.TempDir()
+	ch := &chart.Chart{Metadata: &chart.Metadata{APIVersion: chart.APIVersionV2, Name: "parent", Version: "0.1.0"}}
+	ch.Lock = &chart.Lock{}
+	for _, name := range []string{"a", "b", "c"} {
+		ch.Metadata.Dependencies = append(ch.Metadata.Dependencies, &chart.Dependency{Name: name, Version: "1.0.0"})
+		ch.Lock.Dependencies = append(ch.Lock.Dependencies, &chart.Dependency{Name: name, Version: "1.0.0"})
+		ch.AddDependency(&chart.Chart{Metadata: &chart.Metadata{APIVersion: chart.APIVersionV2, Name: name, Version: "1.0.0"}})
+	}
+	digest, err := hashReq(ch.Metadata.Dependencies, ch.Lock.Dependencies)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ch.Lock.Digest = digest
+
+	if err := saveChart(ch, dir); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "parent", chartLockFile)); err != nil {
+		t.Fatalf("expected %s to be written: %v", chartLockFile, err)
+	}
+	loaded, err := loader.LoadDir(filepath.Join(dir, "parent"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded.Lock == nil || loaded.Lock.Digest != digest || len(loaded.Lock.Dependencies) != 3 {
+		t.Errorf("saved lock = %+v, want digest %s with 3 dependencies", loaded.Lock, digest)
+	}
+}
@@ -0,0 +1,165 @@
+/*
+Copyright AppsCode Inc. and Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmds
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/chart/loader"
+	"helm.sh/helm/v3/pkg/chartutil"
+)
+
+const (
+	compressionGzip = "gzip"
+	compressionZstd = "zstd"
+	compressionNone = "none"
+)
+
+var (
+	compressions = []string{compressionGzip, compressionZstd, compressionNone}
+	zstdMagic    = []byte{0x28, 0xb5, 0x2f, 0xfd}
+)
+
+// loadArchiveData loads a chart archive compressed with gzip or zstd, or an uncompressed tarball.
+func loadArchiveData(data []byte) (*chart.Chart, error) {
+	if isTarball(data) {
+		// Helm only reads gzip compressed tarballs
+		var err error
+		if data, err = gzipData(data); err != nil {
+			return nil, err
+		}
+	} else if bytes.HasPrefix(data, zstdMagic) {
+		dec, err := zstd.NewReader(bytes.NewReader(data), zstd.WithDecoderMaxMemory(uint64(loader.MaxDecompressedChartSize)))
+		if err != nil {
+			return nil, err
+		}
+		defer dec.Close()
+		tarball, err := io.ReadAll(dec)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decompress zstd chart archive: %v", err)
+		}
+		// Helm only reads gzip compressed tarballs
+		if data, err = gzipData(tarball); err != nil {
+			return nil, err
+		}
+	}
+	return loader.LoadArchive(bytes.NewReader(data))
+}
+
+// saveArchive packages ch into dir like chartutil.Save, using the given compression.
+// The archive is named <chart>-<version>.tgz, .tar.zst or .tar accordingly.
+func saveArchive(ch *chart.Chart, dir, compression string) (string, error) {
+	if compression == compressionGzip {
+		return chartutil.Save(ch, dir)
+	}
+
+	tmp, err := os.MkdirTemp("", "chart-packer-archive-")
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = os.RemoveAll(tmp) }()
+	tgz, err := chartutil.Save(ch, tmp)
+	if err != nil {
+		return "", err
+	}
+	f, err := os.Open(tgz)
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = f.Close() }()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return "", err
+	}
+	tarball, err := io.ReadAll(gz)
+	if err != nil {
+		return "", err
+	}
+
+	name := strings.TrimSuffix(filepath.Base(tgz), ".tgz") + ".tar"
+	data := tarball
+	switch compression {
+	case compressionZstd:
+		enc, err := zstd.NewWriter(nil, zstd.WithEncoderLevel(zstd.SpeedBestCompression))
+		if err != nil {
+			return "", err
+		}
+		data = enc.EncodeAll(tarball, nil)
+		_ = enc.Close()
+		name += ".zst"
+	case compressionNone:
+	default:
+		return "", fmt.Errorf("unsupported compression %q, must be one of %s", compression, strings.Join(compressions, ", "))
+	}
+
+	archive := filepath.Join(dir, name)
+	if err := os.WriteFile(archive, data, 0o644); err != nil {
+		return "", err
+	}
+	return archive, nil
+}
+
+// isTarball checks for the ustar magic of POSIX and GNU tar headers.
+func isTarball(data []byte) bool {
+	return len(data) >= 262 && bytes.Equal(data[257:262], []byte("ustar"))
+}
+
+// chartDir returns dir if it contains a Chart.yaml, or else its only subdirectory containing one,
+// like the directory a chart layer of an OCI image is extracted into.
+func chartDir(dir string) (string, error) {
+	if _, err := os.Stat(filepath.Join(dir, chartutil.ChartfileName)); err == nil {
+		return dir, nil
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return "", err
+	}
+	var found []string
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
+		}
+		if _, err := os.Stat(filepath.Join(dir, e.Name(), chartutil.ChartfileName)); err == nil {
+			found = append(found, filepath.Join(dir, e.Name()))
+		}
+	}
+	if len(found) != 1 {
+		// let the loader report the missing Chart.yaml
+		return dir, nil
+	}
+	return found[0], nil
+}
+
+func gzipData(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	if _, err := w.Write(data); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
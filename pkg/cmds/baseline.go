@@ -0,0 +1,57 @@
+/*
+Copyright AppsCode Inc. and Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmds
+
+import (
+	"fmt"
+	"strings"
+
+	"helm.sh/helm/v3/pkg/chart"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// checkBaseline fails if a CRD of the baseline chart is not packaged anymore, unless it matches
+// an allowed glob pattern by kind.group or CRD name, e.g. Foo.example.com or foos.example.com.
+func checkBaseline(baseline map[schema.GroupKind]*chart.File, packaged map[schema.GroupKind]bool, allowed []string) error {
+	var removed []string
+	for _, key := range sortedCRDKeys(baseline) {
+		if packaged[key] {
+			continue
+		}
+		names := []string{key.Kind + "." + key.Group}
+		if crd, err := decodeCRD(baseline[key].Data); err == nil {
+			names = append(names, crd.Name)
+		}
+		ok := false
+		for _, name := range names {
+			matched, err := matchAny(allowed, name)
+			if err != nil {
+				return err
+			}
+			ok = ok || matched
+		}
+		if ok {
+			fmt.Printf("Allowed removal of CRD %s/%s of the baseline chart\n", key.Kind, key.Group)
+			continue
+		}
+		removed = append(removed, key.Kind+"/"+key.Group)
+	}
+	if len(removed) > 0 {
+		return fmt.Errorf("CRDs of the baseline chart are missing from the output (use --allow-crd-removal to accept):\n  - %s", strings.Join(removed, "\n  - "))
+	}
+	return nil
+}
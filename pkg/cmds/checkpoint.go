@@ -0,0 +1,147 @@
+/*
+Copyright AppsCode Inc. and Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmds
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/spf13/pflag"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	checkpointGenerated = "generated"
+	checkpointPublished = "published"
+)
+
+// checkpointOptions records the charts completed by a run in a manifest, so an interrupted
+// run can be resumed without redoing them.
+type checkpointOptions struct {
+	path   string
+	resume bool
+}
+
+func (o *checkpointOptions) AddFlags(fs *pflag.FlagSet) {
+	fs.StringVar(&o.path, "checkpoint", o.path, "Path to a manifest recording each chart completed by the run, used by --resume")
+	fs.BoolVar(&o.resume, "resume", o.resume, "If true, skip the charts recorded as completed in the --checkpoint manifest of an interrupted run")
+}
+
+// checkpointEntry is an input completed by a run. Inputs are identified by their reference and,
+// when known without loading the chart, their digest, so completed inputs are not loaded again.
+type checkpointEntry struct {
+	Input  string    `json:"input"`
+	Digest string    `json:"digest,omitempty"`
+	Step   string    `json:"step"`
+	Charts []string  `json:"charts,omitempty"`
+	CRDs   []string  `json:"crds,omitempty"`
+	Time   time.Time `json:"time"`
+}
+
+type checkpoint struct {
+	path   string
+	Inputs []checkpointEntry `json:"inputs"`
+}
+
+// open loads the checkpoint manifest when resuming, or starts a new one. It returns nil if no
+// checkpoint is requested.
+func (o *checkpointOptions) open() (*checkpoint, error) {
+	if o.path == "" {
+		if o.resume {
+			return nil, fmt.Errorf("--resume requires --checkpoint")
+		}
+		return nil, nil
+	}
+	c := &checkpoint{path: o.path}
+	if !o.resume {
+		return c, c.write()
+	}
+	data, err := os.ReadFile(o.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return c, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if err := yaml.Unmarshal(data, c); err != nil {
+		return nil, fmt.Errorf("failed to parse checkpoint %s: %v", o.path, err)
+	}
+	return c, nil
+}
+
+// completed returns the entry of the input if the step was completed. It is nil-safe.
+func (c *checkpoint) completed(step, input, digest string) *checkpointEntry {
+	if c == nil {
+		return nil
+	}
+	for i, e := range c.Inputs {
+		if e.Step == step && e.Input == input && e.Digest == digest {
+			return &c.Inputs[i]
+		}
+	}
+	return nil
+}
+
+// record marks the step of the input as completed. It is nil-safe.
+func (c *checkpoint) record(e checkpointEntry) error {
+	if c == nil {
+		return nil
+	}
+	e.Time = time.Now().UTC()
+	c.Inputs = append(c.Inputs, e)
+	return c.write()
+}
+
+// write replaces the manifest atomically, so an interrupted write does not lose the recorded charts.
+func (c *checkpoint) write() error {
+	data, err := yaml.Marshal(c)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(c.path), 0o755); err != nil {
+		return err
+	}
+	tmp := c.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, c.path)
+}
+
+// inputDigest identifies the content of input without loading it: the expected --sha256 if
+// set, or the digest of a local archive. It is empty for other inputs.
+func inputDigest(input, expected string) (string, error) {
+	if expected != "" {
+		return "sha256:" + strings.TrimPrefix(expected, "sha256:"), nil
+	}
+	fi, err := os.Stat(input)
+	if err != nil || !fi.Mode().IsRegular() {
+		return "", nil
+	}
+	data, err := os.ReadFile(input)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return "sha256:" + hex.EncodeToString(sum[:]), nil
+}
@@ -0,0 +1,71 @@
+/*
+Copyright AppsCode Inc. and Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmds
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCheckpointResumeByInputDigest(t *testing.T) {
+	dir := t.TempDir()
+	archive := filepath.Join(dir, "demo-0.1.0.tgz")
+	if err := os.WriteFile(archive, []byte("v1"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "checkpoint.yaml")
+
+	c, err := (&checkpointOptions{path: path}).open()
+	if err != nil {
+		t.Fatal(err)
+	}
+	digest, err := inputDigest(archive, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.record(checkpointEntry{Input: archive, Digest: digest, Step: checkpointGenerated}); err != nil {
+		t.Fatal(err)
+	}
+
+	resumed, err := (&checkpointOptions{path: path, resume: true}).open()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resumed.completed(checkpointGenerated, archive, digest) == nil {
+		t.Errorf("expected %s to be completed", archive)
+	}
+	if resumed.completed(checkpointPublished, archive, digest) != nil {
+		t.Errorf("expected %s not to be published", archive)
+	}
+
+	// A changed archive at the same path is processed again
+	if err := os.WriteFile(archive, []byte("v2"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	changed, err := inputDigest(archive, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resumed.completed(checkpointGenerated, archive, changed) != nil {
+		t.Errorf("expected the changed %s not to be completed", archive)
+	}
+
+	if d, _ := inputDigest("oci://example.com/charts/demo:0.1.0", "abc"); d != "sha256:abc" {
+		t.Errorf("inputDigest() with --sha256 = %q, want sha256:abc", d)
+	}
+}
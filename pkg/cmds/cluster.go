@@ -0,0 +1,143 @@
+/*
+Copyright AppsCode Inc. and Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmds
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/pflag"
+	"helm.sh/helm/v3/pkg/action"
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/chart/loader"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/discovery/cached/memory"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/restmapper"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+// clusterOptions selects the cluster the generated charts are checked against, like the
+// --kubeconfig and --kube-context flags of helm and kubectl.
+type clusterOptions struct {
+	kubeconfig  string
+	kubeContext string
+	dryRun      bool
+}
+
+func (o *clusterOptions) AddFlags(fs *pflag.FlagSet) {
+	fs.StringVar(&o.kubeconfig, "kubeconfig", o.kubeconfig, "Path to the kubeconfig file used by --cluster-dry-run (defaults to $KUBECONFIG or ~/.kube/config)")
+	fs.StringVar(&o.kubeContext, "kube-context", o.kubeContext, "Name of the kubeconfig context used by --cluster-dry-run")
+	fs.BoolVar(&o.dryRun, "cluster-dry-run", o.dryRun, "If true, run helm install --dry-run=server of the generated chart against the cluster, surfacing admission, webhook and API availability issues")
+}
+
+// clientConfig loads the selected kubeconfig context.
+func (o *clusterOptions) clientConfig() clientcmd.ClientConfig {
+	rules := clientcmd.NewDefaultClientConfigLoadingRules()
+	rules.ExplicitPath = o.kubeconfig
+	overrides := &clientcmd.ConfigOverrides{CurrentContext: o.kubeContext}
+	return clientcmd.NewNonInteractiveDeferredLoadingClientConfig(rules, overrides)
+}
+
+// restConfig loads the client config of the selected kubeconfig context.
+func (o *clusterOptions) restConfig() (*rest.Config, error) {
+	return o.clientConfig().ClientConfig()
+}
+
+// dryRunInstall installs the chart in chartDir with vals like helm install --dry-run=server, in the
+// namespace of the kubeconfig context. The rendered manifests are discarded.
+func (o *clusterOptions) dryRunInstall(chartDir string, vals map[string]any) error {
+	config, err := o.restConfig()
+	if err != nil {
+		return fmt.Errorf("failed to load kubeconfig: %v", err)
+	}
+	namespace, _, err := o.clientConfig().Namespace()
+	if err != nil {
+		return fmt.Errorf("failed to load kubeconfig: %v", err)
+	}
+	ch, err := loader.Load(chartDir)
+	if err != nil {
+		return err
+	}
+
+	cfg := new(action.Configuration)
+	getter := &restClientGetter{config: config, clientConfig: o.clientConfig()}
+	if err := cfg.Init(getter, namespace, os.Getenv("HELM_DRIVER"), func(string, ...any) {}); err != nil {
+		return err
+	}
+	install := action.NewInstall(cfg)
+	install.DryRunOption = "server"
+	install.ReleaseName = ch.Name()
+	install.Namespace = namespace
+	if _, err := install.Run(ch, vals); err != nil {
+		return fmt.Errorf("dry-run install of chart %s: %v", ch.Name(), err)
+	}
+	fmt.Printf("Dry-run install of chart %s accepted by the cluster\n", ch.Name())
+	return nil
+}
+
+// restClientGetter gives the helm action clients the config loaded by clusterOptions.
+type restClientGetter struct {
+	config       *rest.Config
+	clientConfig clientcmd.ClientConfig
+}
+
+func (g *restClientGetter) ToRESTConfig() (*rest.Config, error) {
+	return rest.CopyConfig(g.config), nil
+}
+
+func (g *restClientGetter) ToDiscoveryClient() (discovery.CachedDiscoveryInterface, error) {
+	dc, err := discovery.NewDiscoveryClientForConfig(g.config)
+	if err != nil {
+		return nil, err
+	}
+	return memory.NewMemCacheClient(dc), nil
+}
+
+func (g *restClientGetter) ToRESTMapper() (meta.RESTMapper, error) {
+	dc, err := g.ToDiscoveryClient()
+	if err != nil {
+		return nil, err
+	}
+	mapper := restmapper.NewDeferredDiscoveryRESTMapper(dc)
+	return restmapper.NewShortcutExpander(mapper, dc, nil), nil
+}
+
+func (g *restClientGetter) ToRawKubeConfigLoader() clientcmd.ClientConfig {
+	return g.clientConfig
+}
+
+// dryRunApplyCRDs applies the CRDs of crdMap with a server-side dry-run. helm does not send crds/
+// to the server with --dry-run, so CRD charts are checked this way.
+func (o *clusterOptions) dryRunApplyCRDs(crdMap map[schema.GroupKind]*chart.File) error {
+	config, err := o.restConfig()
+	if err != nil {
+		return fmt.Errorf("failed to load kubeconfig: %v", err)
+	}
+	rejected, err := applyCRDs(config, crdMap, true)
+	if err != nil {
+		return err
+	}
+	if len(rejected) > 0 {
+		return fmt.Errorf("cluster rejected CRDs:\n  - %s", strings.Join(rejected, "\n  - "))
+	}
+	fmt.Printf("Dry-run apply of %d CRDs accepted by the cluster\n", len(crdMap))
+	return nil
+}
@@ -18,6 +18,7 @@ package cmds
 
 import (
 	"os"
+	"strings"
 
 	"github.com/spf13/cobra"
 )
@@ -76,3 +77,68 @@ $ chart-packer completion fish > ~/.config/fish/completions/chart-packer.fish
 	}
 	return cmd
 }
+
+// completeChartInput completes --input with the charts and versions of the repositories added with
+// `helm repo add`, as <repo>/<chart>@<version>, and the tags of oci:// charts. Local paths are
+// completed by the shell.
+func completeChartInput(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if strings.HasPrefix(toComplete, ociInputPrefix) {
+		idx := strings.LastIndex(toComplete, ":")
+		if idx <= strings.LastIndex(toComplete, "/") {
+			return nil, cobra.ShellCompDirectiveNoFileComp | cobra.ShellCompDirectiveNoSpace
+		}
+		ref, prefix := toComplete[:idx], toComplete[idx+1:]
+		plainHTTP, _ := cmd.Flags().GetBool("plain-http")
+		tags, err := listOCITags(ref, plainHTTP)
+		if err != nil {
+			cobra.CompDebugln(err.Error(), true)
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+		var result []string
+		for _, tag := range tags {
+			if strings.HasPrefix(tag, prefix) {
+				result = append(result, ref+":"+tag)
+			}
+		}
+		return result, cobra.ShellCompDirectiveNoFileComp
+	}
+
+	repos, err := helmRepos()
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveDefault
+	}
+	repoName, rest, hasSlash := strings.Cut(toComplete, "/")
+	if !hasSlash {
+		var result []string
+		for _, r := range repos {
+			if strings.HasPrefix(r.Name, toComplete) {
+				result = append(result, r.Name+"/")
+			}
+		}
+		if len(result) == 0 {
+			return nil, cobra.ShellCompDirectiveDefault
+		}
+		return result, cobra.ShellCompDirectiveNoSpace
+	}
+
+	index, err := helmRepoIndex(repoName)
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveDefault
+	}
+	name, version, hasVersion := strings.Cut(rest, "@")
+	var result []string
+	if hasVersion {
+		for _, cv := range index.Entries[name] {
+			if strings.HasPrefix(cv.Version, version) {
+				result = append(result, repoName+"/"+name+"@"+cv.Version+"\t"+cv.AppVersion)
+			}
+		}
+		return result, cobra.ShellCompDirectiveNoFileComp
+	}
+	for chartName, versions := range index.Entries {
+		if strings.HasPrefix(chartName, name) && len(versions) > 0 {
+			result = append(result, repoName+"/"+chartName+"\t"+versions[0].Description)
+		}
+	}
+	return result, cobra.ShellCompDirectiveNoFileComp | cobra.ShellCompDirectiveNoSpace
+}
@@ -0,0 +1,146 @@
+/*
+Copyright AppsCode Inc. and Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmds
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	configFileName = ".chart-packer.yaml"
+	envPrefix      = "CHART_PACKER_"
+)
+
+// applyFlagDefaults sets the flags of cmd that are not passed on the command line from
+// CHART_PACKER_<FLAG_NAME> environment variables, then from the config file. Top-level keys of
+// the config file apply to every command, keys under a command path like crd-only or
+// "inspect crds" only to that command:
+//
+//	keyring: /etc/chart-packer/pubring.gpg
+//	crd-only:
+//	  output: ./charts
+//	  strip-crd-annotation: [controller-gen.kubebuilder.io/*]
+func applyFlagDefaults(cmd *cobra.Command, configFile string) error {
+	config, source, err := loadConfig(configFile)
+	if err != nil {
+		return err
+	}
+	values := map[string]any{}
+	for k, v := range config {
+		if _, isSection := v.(map[string]any); !isSection || cmd.Flags().Lookup(k) != nil {
+			values[k] = v
+		}
+	}
+	path := strings.TrimPrefix(cmd.CommandPath(), cmd.Root().Name()+" ")
+	if section, ok := config[path].(map[string]any); ok {
+		for k, v := range section {
+			values[k] = v
+		}
+	}
+
+	var errs []error
+	cmd.Flags().VisitAll(func(f *pflag.Flag) {
+		if f.Changed || f.Name == "help" || f.Name == "config" {
+			return
+		}
+		env := envPrefix + strings.ToUpper(strings.ReplaceAll(f.Name, "-", "_"))
+		if value, ok := os.LookupEnv(env); ok {
+			if err := cmd.Flags().Set(f.Name, value); err != nil {
+				errs = append(errs, fmt.Errorf("invalid value for --%s from %s: %v", f.Name, env, err))
+			}
+			return
+		}
+		if value, ok := values[f.Name]; ok {
+			if err := setFlagValue(cmd.Flags(), f, value); err != nil {
+				errs = append(errs, fmt.Errorf("invalid value for --%s from %s: %v", f.Name, source, err))
+			}
+		}
+	})
+	return errors.Join(errs...)
+}
+
+// setFlagValue sets f from a config file value: a scalar, a list for repeatable flags or a
+// map for key=value flags.
+func setFlagValue(flags *pflag.FlagSet, f *pflag.Flag, value any) error {
+	switch v := value.(type) {
+	case []any:
+		items := make([]string, 0, len(v))
+		for _, item := range v {
+			items = append(items, fmt.Sprint(item))
+		}
+		sv, ok := f.Value.(pflag.SliceValue)
+		if !ok {
+			return fmt.Errorf("flag does not accept a list")
+		}
+		if err := sv.Replace(items); err != nil {
+			return err
+		}
+		f.Changed = true
+		return nil
+	case map[string]any:
+		pairs := make([]string, 0, len(v))
+		for k, item := range v {
+			pairs = append(pairs, k+"="+fmt.Sprint(item))
+		}
+		sort.Strings(pairs)
+		return flags.Set(f.Name, strings.Join(pairs, ","))
+	case nil:
+		return nil
+	default:
+		return flags.Set(f.Name, fmt.Sprint(v))
+	}
+}
+
+// loadConfig reads the config file passed with --config or CHART_PACKER_CONFIG, or else the
+// .chart-packer.yaml of the working or home directory, if any.
+func loadConfig(configFile string) (map[string]any, string, error) {
+	if configFile == "" {
+		configFile = os.Getenv(envPrefix + "CONFIG")
+	}
+	candidates := []string{configFile}
+	if configFile == "" {
+		candidates = []string{configFileName}
+		if home, err := os.UserHomeDir(); err == nil {
+			candidates = append(candidates, filepath.Join(home, configFileName))
+		}
+	}
+
+	for _, filename := range candidates {
+		data, err := os.ReadFile(filename)
+		if errors.Is(err, fs.ErrNotExist) && configFile == "" {
+			continue
+		} else if err != nil {
+			return nil, "", fmt.Errorf("failed to read config file: %v", err)
+		}
+		config := map[string]any{}
+		if err := yaml.Unmarshal(data, &config); err != nil {
+			return nil, "", fmt.Errorf("failed to parse config file %s: %v", filename, err)
+		}
+		return config, filename, nil
+	}
+	return nil, "", nil
+}
@@ -0,0 +1,117 @@
+/*
+Copyright AppsCode Inc. and Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmds
+
+import (
+	"k8s.io/apiextensions-apiserver/pkg/apis/apiextensions"
+	"k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/install"
+	crdv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	crdv1beta1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/yaml"
+)
+
+var crdScheme = runtime.NewScheme()
+
+func init() {
+	install.Install(crdScheme)
+}
+
+// convertCRDToV1 converts an apiextensions.k8s.io/v1beta1 CRD into apiextensions.k8s.io/v1.
+// Data that is not a v1beta1 CRD is returned unchanged with converted set to false.
+func convertCRDToV1(data []byte) (out []byte, converted bool, err error) {
+	var tm metav1.TypeMeta
+	if err := yaml.Unmarshal(data, &tm); err != nil {
+		return nil, false, err
+	}
+	if tm.APIVersion != crdv1beta1.SchemeGroupVersion.String() || tm.Kind != "CustomResourceDefinition" {
+		return data, false, nil
+	}
+
+	var in crdv1beta1.CustomResourceDefinition
+	if err := yaml.Unmarshal(data, &in); err != nil {
+		return nil, false, err
+	}
+	// apply v1beta1 defaults (e.g. spec.version -> spec.versions) before converting
+	crdScheme.Default(&in)
+
+	var internal apiextensions.CustomResourceDefinition
+	if err := crdScheme.Convert(&in, &internal, nil); err != nil {
+		return nil, false, err
+	}
+	var crd crdv1.CustomResourceDefinition
+	if err := crdScheme.Convert(&internal, &crd, nil); err != nil {
+		return nil, false, err
+	}
+	crd.TypeMeta = metav1.TypeMeta{
+		APIVersion: crdv1.SchemeGroupVersion.String(),
+		Kind:       "CustomResourceDefinition",
+	}
+
+	// v1 requires a schema for every version and no longer supports spec.preserveUnknownFields.
+	// Keep the v1beta1 pruning behavior by marking the root of the schema instead.
+	preserveUnknownFields := crd.Spec.PreserveUnknownFields
+	crd.Spec.PreserveUnknownFields = false
+	for i := range crd.Spec.Versions {
+		v := &crd.Spec.Versions[i]
+		if v.Schema == nil || v.Schema.OpenAPIV3Schema == nil {
+			v.Schema = &crdv1.CustomResourceValidation{
+				OpenAPIV3Schema: &crdv1.JSONSchemaProps{
+					Type:                   "object",
+					XPreserveUnknownFields: ptr.To(true),
+				},
+			}
+			continue
+		}
+		// the conversion shares one schema across versions, so copy before modifying
+		v.Schema = v.Schema.DeepCopy()
+		if v.Schema.OpenAPIV3Schema.Type == "" {
+			v.Schema.OpenAPIV3Schema.Type = "object"
+		}
+		if preserveUnknownFields {
+			v.Schema.OpenAPIV3Schema.XPreserveUnknownFields = ptr.To(true)
+		}
+	}
+
+	obj, err := runtime.DefaultUnstructuredConverter.ToUnstructured(&crd)
+	if err != nil {
+		return nil, false, err
+	}
+	delete(obj, "status")
+	unstructured.RemoveNestedField(obj, "metadata", "creationTimestamp")
+	out, err = yaml.Marshal(obj)
+	if err != nil {
+		return nil, false, err
+	}
+	return out, true, nil
+}
+
+// decodeCRD parses a CRD manifest into the v1 type, converting v1beta1 CRDs on the fly.
+func decodeCRD(data []byte) (*crdv1.CustomResourceDefinition, error) {
+	data, _, err := convertCRDToV1(data)
+	if err != nil {
+		return nil, err
+	}
+	var crd crdv1.CustomResourceDefinition
+	if err := yaml.Unmarshal(data, &crd); err != nil {
+		return nil, err
+	}
+	return &crd, nil
+}
@@ -0,0 +1,186 @@
+/*
+Copyright AppsCode Inc. and Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmds
+
+import (
+	"testing"
+
+	crdv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"sigs.k8s.io/yaml"
+)
+
+const v1CRD = `apiVersion: apiextensions.k8s.io/v1
+kind: CustomResourceDefinition
+metadata:
+  name: foos.example.com
+spec:
+  group: example.com
+  names:
+    kind: Foo
+    plural: foos
+  scope: Namespaced
+  versions:
+  - name: v1
+    served: true
+    storage: true
+    schema:
+      openAPIV3Schema:
+        type: object
+`
+
+func TestConvertCRDToV1(t *testing.T) {
+	tests := []struct {
+		name          string
+		in            string
+		wantConverted bool
+		wantErr       bool
+		// versions maps the expected version names to whether their schema preserves unknown fields
+		versions map[string]bool
+	}{
+		{
+			name:     "v1 unchanged",
+			in:       v1CRD,
+			versions: map[string]bool{"v1": false},
+		},
+		{
+			name: "not a CRD",
+			in: `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: foo
+`,
+		},
+		{
+			name: "single version with validation",
+			in: `apiVersion: apiextensions.k8s.io/v1beta1
+kind: CustomResourceDefinition
+metadata:
+  name: foos.example.com
+spec:
+  group: example.com
+  version: v1alpha1
+  preserveUnknownFields: false
+  names:
+    kind: Foo
+    plural: foos
+  scope: Namespaced
+  validation:
+    openAPIV3Schema:
+      properties:
+        spec:
+          type: object
+`,
+			wantConverted: true,
+			versions:      map[string]bool{"v1alpha1": false},
+		},
+		{
+			name: "versions without schema",
+			in: `apiVersion: apiextensions.k8s.io/v1beta1
+kind: CustomResourceDefinition
+metadata:
+  name: foos.example.com
+spec:
+  group: example.com
+  names:
+    kind: Foo
+    plural: foos
+  scope: Cluster
+  versions:
+  - name: v1beta1
+    served: true
+    storage: false
+  - name: v1
+    served: true
+    storage: true
+`,
+			wantConverted: true,
+			versions:      map[string]bool{"v1beta1": true, "v1": true},
+		},
+		{
+			// v1beta1 defaults spec.preserveUnknownFields to true
+			name: "preserve unknown fields by default",
+			in: `apiVersion: apiextensions.k8s.io/v1beta1
+kind: CustomResourceDefinition
+metadata:
+  name: foos.example.com
+spec:
+  group: example.com
+  version: v1
+  names:
+    kind: Foo
+    plural: foos
+  scope: Namespaced
+  validation:
+    openAPIV3Schema:
+      type: object
+`,
+			wantConverted: true,
+			versions:      map[string]bool{"v1": true},
+		},
+		{
+			name:    "invalid yaml",
+			in:      "apiVersion: [",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out, converted, err := convertCRDToV1([]byte(tt.in))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("convertCRDToV1() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if converted != tt.wantConverted {
+				t.Errorf("converted = %v, want %v", converted, tt.wantConverted)
+			}
+			if !converted && string(out) != tt.in {
+				t.Errorf("unconverted data was modified:\n%s", out)
+			}
+			if tt.versions == nil {
+				return
+			}
+
+			var crd crdv1.CustomResourceDefinition
+			if err := yaml.UnmarshalStrict(out, &crd); err != nil {
+				t.Fatal(err)
+			}
+			if crd.APIVersion != crdv1.SchemeGroupVersion.String() || crd.Spec.PreserveUnknownFields {
+				t.Errorf("expected a v1 CRD without spec.preserveUnknownFields, got %s %v", crd.APIVersion, crd.Spec.PreserveUnknownFields)
+			}
+			if len(crd.Spec.Versions) != len(tt.versions) {
+				t.Fatalf("got %d versions, want %d", len(crd.Spec.Versions), len(tt.versions))
+			}
+			for _, v := range crd.Spec.Versions {
+				preserve, ok := tt.versions[v.Name]
+				if !ok {
+					t.Errorf("unexpected version %s", v.Name)
+					continue
+				}
+				if v.Schema == nil || v.Schema.OpenAPIV3Schema == nil || v.Schema.OpenAPIV3Schema.Type != "object" {
+					t.Errorf("version %s has no object schema", v.Name)
+					continue
+				}
+				got := v.Schema.OpenAPIV3Schema.XPreserveUnknownFields != nil && *v.Schema.OpenAPIV3Schema.XPreserveUnknownFields
+				if got != preserve {
+					t.Errorf("version %s x-kubernetes-preserve-unknown-fields = %v, want %v", v.Name, got, preserve)
+				}
+			}
+		})
+	}
+}
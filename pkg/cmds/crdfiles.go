@@ -0,0 +1,138 @@
+/*
+Copyright AppsCode Inc. and Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmds
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"helm.sh/helm/v3/pkg/chart"
+)
+
+// Policies for files under crds/ that do not define CRDs, like READMEs or example resources.
+const (
+	nonCRDFilesKeep  = "keep"
+	nonCRDFilesDrop  = "drop"
+	nonCRDFilesError = "error"
+)
+
+var nonCRDFilesPolicies = []string{nonCRDFilesKeep, nonCRDFilesDrop, nonCRDFilesError}
+
+// nonCRDFile is a file under crds/ of a chart or subchart that does not define CRDs.
+type nonCRDFile struct {
+	// source is the path of the file including the chart path, for messages
+	source string
+	file   *chart.File
+}
+
+// isCRDFile reports whether f is a manifest containing only CRDs. Files with template
+// expressions are assumed to be CRDs, since they are handled by --crd-templates.
+func isCRDFile(f *chart.File) bool {
+	if !isManifestFile(f.Name) {
+		return false
+	}
+	if hasTemplateMarkers(f.Data) {
+		return true
+	}
+	items, err := unwrapCRDList(f)
+	if err != nil {
+		return false
+	}
+	for _, item := range items {
+		if _, err := extractCRDKey(item.Data); err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// findNonCRDFiles returns the files under crds/ of ch, and of its subcharts if recursive is true,
+// that do not define CRDs. Subcharts matching the skip globs are ignored.
+func findNonCRDFiles(ch *chart.Chart, skip []string, recursive bool) ([]nonCRDFile, error) {
+	var result []nonCRDFile
+	for _, f := range ch.Files {
+		if strings.HasPrefix(f.Name, "crds/") && !isCRDFile(f) {
+			result = append(result, nonCRDFile{source: ch.ChartFullPath() + "/" + f.Name, file: f})
+		}
+	}
+	if !recursive {
+		return result, nil
+	}
+	for _, dep := range ch.Dependencies() {
+		aliases, err := selectedAliases(ch, dep, skip)
+		if err != nil {
+			return nil, err
+		}
+		if len(aliases) == 0 {
+			continue
+		}
+		files, err := findNonCRDFiles(dep, skip, recursive)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, files...)
+	}
+	return result, nil
+}
+
+// nonCRDFilesOf finds the non-CRD files of ch and applies policy to them, returning the files to keep.
+func nonCRDFilesOf(ch *chart.Chart, skip []string, recursive bool, policy string) ([]*chart.File, error) {
+	files, err := findNonCRDFiles(ch, skip, recursive)
+	if err != nil {
+		return nil, err
+	}
+	kept, err := applyNonCRDFilesPolicy(files, policy)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]*chart.File, 0, len(kept))
+	for _, f := range kept {
+		result = append(result, f.file)
+	}
+	return result, nil
+}
+
+// applyNonCRDFilesPolicy reports the non-CRD files according to policy. It returns the files to keep,
+// which is empty unless policy is keep, and an error if policy is error and such files exist.
+func applyNonCRDFilesPolicy(files []nonCRDFile, policy string) ([]nonCRDFile, error) {
+	if !slices.Contains(nonCRDFilesPolicies, policy) {
+		return nil, fmt.Errorf("unsupported --non-crd-files %q, must be one of %s", policy, strings.Join(nonCRDFilesPolicies, ", "))
+	}
+	if len(files) == 0 {
+		return nil, nil
+	}
+
+	switch policy {
+	case nonCRDFilesError:
+		sources := make([]string, 0, len(files))
+		for _, f := range files {
+			sources = append(sources, f.source)
+		}
+		return nil, fmt.Errorf("crds/ contains files that are not CRDs: %s", strings.Join(sources, ", "))
+	case nonCRDFilesKeep:
+		for _, f := range files {
+			fmt.Printf("Keeping non-CRD file %s\n", f.source)
+		}
+		return files, nil
+	default:
+		for _, f := range files {
+			fmt.Printf("Dropping non-CRD file %s\n", f.source)
+		}
+		return nil, nil
+	}
+}
@@ -19,19 +19,34 @@ package cmds
 import (
 	"fmt"
 	"os"
+	"path/filepath"
+	"slices"
 	"strings"
 
 	"github.com/spf13/cobra"
 	"helm.sh/helm/v3/pkg/chart"
-	"helm.sh/helm/v3/pkg/chart/loader"
-	"helm.sh/helm/v3/pkg/chartutil"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 )
 
 func NewCmdGenerateCRDLessChart() *cobra.Command {
 	var (
-		input  string
-		output string
-		semver = true
+		input        string
+		output       string
+		semver       = true
+		mdOpts       = newMetadataOptions()
+		inOpts       = newInputOptions()
+		valOpts      valuesOptions
+		crdHook      bool
+		renderCRDs   bool
+		crdTemplates = crdTemplatesWarn
+		failOnCRs    bool
+		instOpts     = newInstallerOptions()
+		strict       bool
+		clusterOpts  clusterOptions
+
+		pruneDisabled     bool
+		upgradeAPIVersion bool
+		nonCRDPolicy      = nonCRDFilesDrop
 	)
 	cmd := &cobra.Command{
 		Use:                   "crd-less",
@@ -39,21 +54,127 @@ func NewCmdGenerateCRDLessChart() *cobra.Command {
 		DisableFlagsInUseLine: true,
 		DisableAutoGenTag:     true,
 		Run: func(cmd *cobra.Command, args []string) {
+			if renderCRDs && !cmd.Flags().Changed("crd-templates") {
+				crdTemplates = crdTemplatesRender
+			}
+			vals, err := valOpts.merge()
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
+				exit(1)
+			}
+
 			// Load the chart archive directly using Helm SDK
-			ch, err := loader.Load(input)
+			ch, err := inOpts.load(input, strict)
 			if err != nil {
 				fmt.Printf("Error loading chart archive: %v\n", err)
-				os.Exit(1)
+				exit(1)
 			}
 			newChartName := ch.Metadata.Name + "-certified"
 
+			if upgradeAPIVersion {
+				upgraded, err := upgradeChartAPIVersion(ch)
+				if err != nil {
+					fmt.Printf("Error upgrading chart apiVersion: %v\n", err)
+					exit(1)
+				}
+				for _, name := range upgraded {
+					fmt.Printf("Upgraded chart %s to apiVersion %s\n", name, chart.APIVersionV2)
+				}
+			}
+
+			if pruneDisabled {
+				pruned, err := pruneDisabledDependencies(ch, vals)
+				if err != nil {
+					fmt.Printf("Error evaluating dependency conditions: %v\n", err)
+					exit(1)
+				}
+				for _, name := range pruned {
+					fmt.Printf("Pruned disabled subchart %s\n", name)
+				}
+			}
+
+			// Keep the CRDs to be applied by a hook Job before they are removed
+			var hookFiles []*chart.File
+			if crdHook {
+				if err := processCRDTemplates(ch, crdTemplates, vals); err != nil {
+					fmt.Printf("Error: %v\n", err)
+					exit(1)
+				}
+				crdMap := map[schema.GroupKind]*chart.File{}
+				if err := collectCRDs(os.Stdout, ch, ch.Name(), nil, crdMap, map[schema.GroupKind]string{}); err != nil {
+					fmt.Printf("Error: %v\n", err)
+					exit(1)
+				}
+				// apiextensions.k8s.io/v1beta1 can not be applied to current clusters
+				for key, file := range crdMap {
+					data, converted, err := convertCRDToV1(file.Data)
+					if err != nil {
+						fmt.Printf("Error converting CRD %s/%s to v1: %v\n", key.Kind, key.Group, err)
+						exit(1)
+					}
+					if converted {
+						crdMap[key] = &chart.File{Name: file.Name, Data: data}
+					}
+				}
+				if hookFiles, err = instOpts.files(crdMap); err != nil {
+					fmt.Printf("Error generating CRD installer Job: %v\n", err)
+					exit(1)
+				}
+				fmt.Printf("Embedding %d CRDs into a pre-install/pre-upgrade hook Job\n", len(crdMap))
+			}
+
 			// Remove CRDs from the main chart and recursively from dependencies
-			removeCRDsFromChart(ch)
+			kept, err := nonCRDFilesOf(ch, nil, true, nonCRDPolicy)
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
+				exit(1)
+			}
+			removed := crdGroupKinds(ch, vals)
+			removeCRDsFromChart(ch, kept)
 
-			renameChart(ch, newChartName)
+			// Scan the rendered templates for CRDs and custom resources
+			manifests, err := renderManifests(ch, vals, defaultReleaseOptions(ch))
+			if err != nil {
+				fmt.Printf("Warning: failed to render templates, skipping CRD and custom resource scan: %v\n", err)
+			}
+
+			// CRDs generated by templates are invisible to the crds/ directory handling
+			if crds := templateCRDs(manifests); len(crds) > 0 {
+				fmt.Println("Warning: templates still produce CustomResourceDefinitions:")
+				for _, crd := range crds {
+					fmt.Printf("  - %s\n", crd)
+				}
+			}
+
+			// Custom resources created by the remaining templates need the CRD chart installed first,
+			// unless the CRDs are applied by the hook Job
+			if !crdHook {
+				if refs := customResourceRefs(manifests, removed); len(refs) > 0 {
+					fmt.Println("Templates using custom resources whose CRDs were removed (install the CRD chart first):")
+					for _, ref := range refs {
+						fmt.Printf("  - %s\n", ref)
+					}
+					if failOnCRs {
+						exit(1)
+					}
+				}
+			}
+
+			src := *ch.Metadata
+			renameChart(ch, newChartName, mdOpts.nameAnnotationKeys())
 			if semver {
 				ch.Metadata.Version = strings.TrimPrefix(ch.Metadata.Version, "v")
 			}
+			if err := mdOpts.apply(ch.Metadata, &src); err != nil {
+				fmt.Printf("Error updating chart metadata: %v\n", err)
+				exit(1)
+			}
+			if filled, err := mdOpts.enrich(ch.Metadata, fileData(ch.Files, "doc.yaml")); err != nil {
+				fmt.Printf("Error: %v\n", err)
+				exit(1)
+			} else if len(filled) > 0 {
+				fmt.Printf("Filled %s of chart %s from doc.yaml\n", strings.Join(filled, ", "), newChartName)
+			}
 
 			for _, f := range ch.Files {
 				if f.Name == "doc.yaml" {
@@ -65,32 +186,74 @@ func NewCmdGenerateCRDLessChart() *cobra.Command {
 					break
 				}
 			}
+			// Drop files matched by .helmignore
+			ignored, err := removeIgnoredFiles(ch)
+			if err != nil {
+				fmt.Printf("Error parsing .helmignore: %v\n", err)
+				exit(1)
+			}
+			for _, name := range ignored {
+				fmt.Printf("Skipping %s (matched .helmignore)\n", name)
+			}
+			for _, f := range hookFiles {
+				if strings.HasPrefix(f.Name, "templates/") {
+					ch.Templates = append(ch.Templates, f)
+				} else {
+					ch.Files = append(ch.Files, f)
+				}
+			}
+
 			// Save the modified chart to the output tgz
-			if err := chartutil.SaveDir(ch, output); err != nil {
+			save := startSpan("save", "chart", newChartName)
+			if err := saveChart(ch, output); err != nil {
 				fmt.Printf("Error saving modified chart: %v\n", err)
-				os.Exit(1)
+				exit(1)
+			}
+			save.End()
+			reportWritten(filepath.Join(output, ch.Name()))
+
+			if clusterOpts.dryRun {
+				if err := clusterOpts.dryRunInstall(filepath.Join(output, ch.Name()), vals); err != nil {
+					fmt.Printf("Error: %v\n", err)
+					exit(1)
+				}
 			}
 
 			fmt.Printf("Repackaged chart without CRDs to %s\n", output)
 		},
 	}
 
-	cmd.Flags().StringVar(&input, "input", "", "input helm chart tgz file")
+	cmd.Flags().StringVar(&input, "input", "", "input helm chart tgz, tar.zst or tar file (local path, http(s) URL, <repo>/<chart>[@<version>] of a helm repository or oci://<registry>/<repo>/<chart>:<version>), chart directory or git+https://<repo>//<path>?ref=<ref>")
 	cmd.Flags().StringVar(&output, "output", "", "output helm chart tgz file without CRDs")
 	cmd.Flags().BoolVar(&semver, "semver", semver, "If true, use strict semver version (no v prefix)")
+	cmd.Flags().BoolVar(&strict, "strict", strict, "If true, refuse to repackage input charts without provenance when --verify is set")
+	cmd.Flags().BoolVar(&crdHook, "crd-upgrade-hook", crdHook, "If true, embed the removed CRDs into the chart with a pre-install/pre-upgrade hook Job that applies them")
+	cmd.Flags().StringVar(&crdTemplates, "crd-templates", crdTemplates, "What to do with template expressions in the CRDs embedded by --crd-upgrade-hook, which Helm does not render: error, warn or render them using the chart values and --values/--set")
+	cmd.Flags().BoolVar(&renderCRDs, "render-crds", renderCRDs, "If true, render template expressions in the CRDs embedded by --crd-upgrade-hook using the chart values and --values/--set")
+	_ = cmd.Flags().MarkDeprecated("render-crds", "use --crd-templates=render instead")
+	cmd.Flags().BoolVar(&failOnCRs, "fail-on-custom-resources", failOnCRs, "If true, fail if the remaining templates create custom resources of the removed CRDs")
+	cmd.Flags().BoolVar(&pruneDisabled, "prune-disabled", pruneDisabled, "If true, remove subcharts disabled by their dependency condition or tags in the chart values and --values/--set")
+	cmd.Flags().BoolVar(&upgradeAPIVersion, "upgrade-apiversion", upgradeAPIVersion, "If true, upgrade apiVersion v1 charts (and subcharts) to apiVersion v2, moving requirements.yaml dependencies into Chart.yaml")
+	cmd.Flags().StringVar(&nonCRDPolicy, "non-crd-files", nonCRDPolicy, "What to do with files under crds/ that are not CRDs, like READMEs or examples: keep, drop or error")
+	inOpts.AddFlags(cmd.Flags())
+	valOpts.AddFlags(cmd.Flags())
+	instOpts.AddFlags(cmd.Flags())
+	mdOpts.AddFlags(cmd.Flags())
+	clusterOpts.AddFlags(cmd.Flags())
 	_ = cobra.MarkFlagRequired(cmd.Flags(), "input")
+	_ = cmd.RegisterFlagCompletionFunc("input", completeChartInput)
 	_ = cobra.MarkFlagRequired(cmd.Flags(), "output")
 
 	return cmd
 }
 
-// removeCRDsFromChart removes all files under 'crds/' directory in the given chart
-// and recursively processes any dependency subcharts (both embedded directory and archived).
-func removeCRDsFromChart(ch *chart.Chart) {
+// removeCRDsFromChart removes all files under 'crds/' directory in the given chart, except the ones
+// in keep, and recursively processes any dependency subcharts (both embedded directory and archived).
+func removeCRDsFromChart(ch *chart.Chart, keep []*chart.File) {
 	// Remove CRD files from main chart
 	newFiles := make([]*chart.File, 0, len(ch.Files))
 	for _, f := range ch.Files {
-		if !strings.HasPrefix(f.Name, "crds/") {
+		if !strings.HasPrefix(f.Name, "crds/") || slices.Contains(keep, f) {
 			newFiles = append(newFiles, f)
 		}
 	}
@@ -109,7 +272,7 @@ func removeCRDsFromChart(ch *chart.Chart) {
 		// If the dependency is an embedded archive (common in packaged charts)
 		if dep.Metadata != nil && len(dep.Files) > 0 {
 			// Recursively remove CRDs from this subchart
-			removeCRDsFromChart(dep)
+			removeCRDsFromChart(dep, keep)
 			newDeps = append(newDeps, dep)
 			continue
 		}
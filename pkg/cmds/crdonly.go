@@ -17,14 +17,19 @@ limitations under the License.
 package cmds
 
 import (
+	"bufio"
 	"fmt"
+	"io"
 	"os"
+	"path"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"sort"
 	"strings"
 
 	"github.com/spf13/cobra"
 	"helm.sh/helm/v3/pkg/chart"
-	"helm.sh/helm/v3/pkg/chart/loader"
-	"helm.sh/helm/v3/pkg/chartutil"
 	crdv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime/schema"
@@ -33,9 +38,46 @@ import (
 
 func NewCmdGenerateCRDOnlyChart() *cobra.Command {
 	var (
-		input  string
-		output string
-		semver = true
+		inputs        []string
+		output        string
+		name          string
+		semver        = true
+		renderCRDs    bool
+		crdTemplates  = crdTemplatesWarn
+		convertCRDs   = true
+		strict        bool
+		minCRDAPI     string
+		categories    []string
+		shortNames    string
+		reqStatus     bool
+		valDefaults   bool
+		stripExts     []string
+		stripAnns     []string
+		stripLabels   []string
+		minimal       bool
+		skipDeps      []string
+		perSubchart   bool
+		pruneDisabled bool
+		mdOpts        = newMetadataOptions()
+		inOpts        = newInputOptions()
+		valOpts       valuesOptions
+		installerJob  bool
+		kubeVersions  []string
+		inferKube     bool
+		inventory     = true
+		readmeCRDs    bool
+		nonCRDPolicy  = nonCRDFilesDrop
+		interactive   bool
+		instOpts      = newInstallerOptions()
+		envtest       bool
+		envOpts       = newEnvtestOptions()
+		clusterOpts   clusterOptions
+		baseline      string
+		allHelpers    bool
+		ckptOpts      checkpointOptions
+		allowRemoval  []string
+
+		upgradeAPIVersion bool
 	)
 	cmd := &cobra.Command{
 		Use:                   "crd-only",
@@ -43,139 +85,772 @@ func NewCmdGenerateCRDOnlyChart() *cobra.Command {
 		DisableFlagsInUseLine: true,
 		DisableAutoGenTag:     true,
 		Run: func(cmd *cobra.Command, args []string) {
-			// Load the chart (supports directory or .tgz)
-			ch, err := loader.Load(input)
+			if renderCRDs && !cmd.Flags().Changed("crd-templates") {
+				crdTemplates = crdTemplatesRender
+			}
+			if len(inputs) > 1 && inOpts.sha256 != "" {
+				fmt.Println("Error: --sha256 can only be used with a single --input")
+				exit(1)
+			}
+
+			if perSubchart && name != "" {
+				fmt.Println("Error: --name can not be used with --per-subchart")
+				exit(1)
+			}
+
+			vals, err := valOpts.merge()
 			if err != nil {
-				fmt.Printf("Error loading chart: %v\n", err)
-				os.Exit(1)
+				fmt.Printf("Error: %v\n", err)
+				exit(1)
+			}
+
+			// Load the baseline first, so an unavailable baseline fails the run before any work
+			var baselineCRDs map[schema.GroupKind]*chart.File
+			if baseline != "" {
+				if baselineCRDs, err = loadChartCRDs(newInputOptions(), baseline); err != nil {
+					fmt.Printf("Error loading baseline chart: %v\n", err)
+					exit(1)
+				}
+			}
+
+			ckpt, err := ckptOpts.open()
+			if err != nil {
+				fmt.Printf("Error opening checkpoint: %v\n", err)
+				exit(1)
+			}
+
+			// Inputs completed by an interrupted run are skipped before they are loaded
+			packaged := map[schema.GroupKind]bool{}
+			digests := map[string]string{}
+			var todo []string
+			var skipped []*checkpointEntry
+			for _, input := range inputs {
+				digest, err := inputDigest(input, inOpts.sha256)
+				if err != nil {
+					fmt.Printf("Error reading chart %s: %v\n", input, err)
+					exit(1)
+				}
+				digests[input] = digest
+				if done := ckpt.completed(checkpointGenerated, input, digest); done != nil {
+					skipped = append(skipped, done)
+				} else {
+					todo = append(todo, input)
+				}
+			}
+			// The inputs of a combined chart can only be skipped together
+			if !perSubchart && len(todo) > 0 {
+				todo, skipped = inputs, nil
+			}
+			for _, done := range skipped {
+				fmt.Printf("Skipping chart %s, already generated by the interrupted run\n", done.Input)
+				for _, gk := range done.CRDs {
+					packaged[schema.ParseGroupKind(gk)] = true
+				}
 			}
-			newChartName := ch.Metadata.Name + "-certified-crds"
 
 			// Map to store unique CRDs: key is (group, kind, plural), value is the chart.File and source chart name
 			crdMap := make(map[schema.GroupKind]*chart.File)
 			sourceMap := make(map[schema.GroupKind]string) // for warning messages
 
-			// First: collect CRDs from the main (parent) chart — these take precedence
-			collectCRDs(ch, ch.Name(), crdMap, sourceMap)
+			// The first input is the main chart: its metadata and files are used for the output chart
+			var targets []crdTarget
+			for _, input := range todo {
+				// Load the chart (supports directory, .tgz, URL, OCI reference or git repository)
+				c, err := inOpts.load(input, strict)
+				if err != nil {
+					fmt.Printf("Error loading chart %s: %v\n", input, err)
+					exit(1)
+				}
+
+				if pruneDisabled {
+					pruned, err := pruneDisabledDependencies(c, vals)
+					if err != nil {
+						fmt.Printf("Error evaluating dependency conditions: %v\n", err)
+						exit(1)
+					}
+					for _, name := range pruned {
+						fmt.Printf("Pruned disabled subchart %s\n", name)
+					}
+				}
+
+				// Detect (and optionally render) template expressions left in crds/ files
+				collect := startSpan("collect", "chart", c.Name())
+				if err := processCRDTemplates(c, crdTemplates, vals); err != nil {
+					fmt.Printf("Error rendering CRD templates: %v\n", err)
+					exit(1)
+				}
+
+				if !perSubchart {
+					// Collect CRDs from the parent chart first, then from its subcharts
+					collected := len(crdMap)
+					if err := collectCRDs(os.Stdout, c, c.Name(), skipDeps, crdMap, sourceMap); err != nil {
+						fmt.Printf("Error: %v\n", err)
+						exit(1)
+					}
+					progressf("Collected %d new CRDs from %s", len(crdMap)-collected, c.Name())
+					kept, err := nonCRDFilesOf(c, skipDeps, true, nonCRDPolicy)
+					if err != nil {
+						fmt.Printf("Error: %v\n", err)
+						exit(1)
+					}
+					if len(targets) == 0 {
+						newChartName := name
+						if newChartName == "" {
+							newChartName = c.Metadata.Name + "-certified-crds"
+						}
+						targets = append(targets, crdTarget{chart: c, name: newChartName, crdMap: crdMap, crdSources: sourceMap})
+					}
+					targets[0].sources = append(targets[0].sources, c.Name())
+					targets[0].inputs = append(targets[0].inputs, input)
+					targets[0].nonCRDFiles = append(targets[0].nonCRDFiles, kept...)
+					collect.End()
+					continue
+				}
 
-			// Then: collect from all dependencies (subcharts)
-			for _, dep := range ch.Dependencies() {
-				if dep != nil {
-					collectCRDs(dep, dep.Name(), crdMap, sourceMap)
+				// One chart for the parent's own CRDs and one per subchart
+				own := map[schema.GroupKind]*chart.File{}
+				ownSources := map[schema.GroupKind]string{}
+				collectOwnCRDs(os.Stdout, c, c.Name(), own, ownSources)
+				kept, err := nonCRDFilesOf(c, skipDeps, false, nonCRDPolicy)
+				if err != nil {
+					fmt.Printf("Error: %v\n", err)
+					exit(1)
+				}
+				if len(own) > 0 {
+					targets = append(targets, crdTarget{chart: c, name: c.Metadata.Name + "-certified-crds", inputs: []string{input}, sources: []string{c.Name()}, crdMap: own, crdSources: ownSources, nonCRDFiles: kept})
+				}
+				for _, dep := range c.Dependencies() {
+					aliases, err := selectedAliases(c, dep, skipDeps)
+					if err != nil {
+						fmt.Printf("Error: %v\n", err)
+						exit(1)
+					}
+					if len(aliases) == 0 {
+						fmt.Printf("Skipping CRDs of subchart %s/%s\n", c.Name(), dep.Name())
+						continue
+					}
+					depMap := map[schema.GroupKind]*chart.File{}
+					depSources := map[schema.GroupKind]string{}
+					if err := collectCRDs(os.Stdout, dep, c.Name()+"/"+dependencyLabel(dep, aliases), skipDeps, depMap, depSources); err != nil {
+						fmt.Printf("Error: %v\n", err)
+						exit(1)
+					}
+					if len(depMap) == 0 {
+						fmt.Printf("Skipping subchart %s/%s without CRDs\n", c.Name(), dep.Name())
+						continue
+					}
+					kept, err := nonCRDFilesOf(dep, skipDeps, true, nonCRDPolicy)
+					if err != nil {
+						fmt.Printf("Error: %v\n", err)
+						exit(1)
+					}
+					// Aliased subcharts share a single CRD chart named after the underlying chart
+					targets = append(targets, crdTarget{chart: dep, name: dep.Metadata.Name + "-crds", inputs: []string{input}, sources: []string{dep.Name()}, crdMap: depMap, crdSources: depSources, nonCRDFiles: kept})
 				}
+				collect.End()
 			}
 
-			// Convert to slice
-			var crdFiles []*chart.File
-			for _, file := range crdMap {
-				crdFiles = append(crdFiles, file)
+			// An input is recorded in the checkpoint once all the charts generated from it are written
+			entries := map[string]*checkpointEntry{}
+			remaining := map[string]int{}
+			for _, input := range todo {
+				entries[input] = &checkpointEntry{Input: input, Digest: digests[input], Step: checkpointGenerated}
+			}
+			for _, t := range targets {
+				for _, input := range t.inputs {
+					remaining[input]++
+				}
+			}
+			recordInputs := func(inputs []string) {
+				for _, input := range inputs {
+					if remaining[input]--; remaining[input] > 0 {
+						continue
+					}
+					if err := ckpt.record(*entries[input]); err != nil {
+						fmt.Printf("Error updating checkpoint: %v\n", err)
+						exit(1)
+					}
+				}
+			}
+			// inputs without any chart to generate are completed already
+			for _, input := range todo {
+				if remaining[input] == 0 {
+					remaining[input] = 1
+					recordInputs([]string{input})
+				}
 			}
 
-			var extraFiles []*chart.File
+			// Charts are only written once all of them are generated and checked
+			var generated []generatedChart
+			stdin := bufio.NewReader(os.Stdin)
+			for _, t := range targets {
+				// Let the user curate the CRDs and files of each chart before generating it
+				if interactive {
+					if err := selectTargetContents(&t, !minimal, stdin, os.Stdout); err != nil {
+						fmt.Printf("Error: %v\n", err)
+						exit(1)
+					}
+					if len(t.crdMap) == 0 {
+						fmt.Printf("Skipping chart %s without selected CRDs\n", t.name)
+						generated = append(generated, generatedChart{inputs: t.inputs})
+						continue
+					}
+				}
+				ch, crdMap, newChartName := t.chart, t.crdMap, t.name
 
-			// Collect additional files from the main chart only
-			filesToCopy := []string{
-				"doc.yaml",
-				"README.md",
-				"values.yaml",
-				"values.schema.json",
-				".helmignore",
-			}
-			for _, name := range filesToCopy {
-				for _, f := range ch.Raw {
-					if f.Name == name {
-						if name == "doc.yaml" {
-							if data, err := modifyDocYaml(f.Data, newChartName); err != nil {
-								fmt.Printf("Warning: Failed to modify doc.yaml: %v\n", err)
-							} else {
+				transform := startSpan("transform", "chart", newChartName)
+				reportCRDAPIVersions(crdMap)
+
+				// Upgrade legacy apiextensions.k8s.io/v1beta1 CRDs
+				if convertCRDs {
+					for key, file := range crdMap {
+						data, converted, err := convertCRDToV1(file.Data)
+						if err != nil {
+							fmt.Printf("Error converting CRD %s/%s to v1: %v\n", key.Kind, key.Group, err)
+							exit(1)
+						}
+						if converted {
+							crdMap[key] = &chart.File{Name: file.Name, Data: data}
+							fmt.Printf("Converted CRD %s/%s from apiextensions.k8s.io/v1beta1 to v1\n", key.Kind, key.Group)
+						}
+					}
+				}
+
+				// Add categories and short names
+				if len(categories) > 0 || shortNames != "" {
+					var names map[string][]string
+					if shortNames != "" {
+						var err error
+						if names, err = loadShortNames(shortNames); err != nil {
+							fmt.Printf("Error loading short names file: %v\n", err)
+							exit(1)
+						}
+					}
+					modified, err := mutateCRDs(crdMap, addCRDNames(categories, names))
+					if err != nil {
+						fmt.Printf("Error: %v\n", err)
+						exit(1)
+					}
+					for _, key := range modified {
+						fmt.Printf("Updated categories/shortNames of CRD %s/%s\n", key.Kind, key.Group)
+					}
+				}
+
+				// Remove internal annotations and labels
+				if len(stripAnns) > 0 || len(stripLabels) > 0 {
+					modified, err := mutateCRDs(crdMap, stripCRDMetadata(stripAnns, stripLabels))
+					if err != nil {
+						fmt.Printf("Error: %v\n", err)
+						exit(1)
+					}
+					for _, key := range modified {
+						fmt.Printf("Stripped annotations/labels from CRD %s/%s\n", key.Kind, key.Group)
+					}
+				}
+
+				// Remove vendor schema extensions
+				if len(stripExts) > 0 {
+					removed := map[schema.GroupKind][]string{}
+					modified, err := mutateCRDs(crdMap, stripSchemaExtensions(stripExts, removed))
+					if err != nil {
+						fmt.Printf("Error: %v\n", err)
+						exit(1)
+					}
+					for _, key := range modified {
+						fmt.Printf("Stripped %s from CRD %s/%s\n", strings.Join(removed[key], ", "), key.Kind, key.Group)
+					}
+				}
+
+				transform.End()
+				progressf("Processed %d CRDs of chart %s", len(crdMap), newChartName)
+
+				validate := startSpan("validate", "chart", newChartName)
+				if strict && minCRDAPI == "" {
+					minCRDAPI = crdv1.SchemeGroupVersion.Version
+				}
+				if minCRDAPI != "" {
+					if err := checkMinCRDAPIVersion(crdMap, minCRDAPI); err != nil {
+						fmt.Printf("Error: %v\n", err)
+						exit(1)
+					}
+				}
+
+				if reqStatus {
+					missing, err := crdsWithoutStatusSubresource(crdMap)
+					if err != nil {
+						fmt.Printf("Error: %v\n", err)
+						exit(1)
+					}
+					if len(missing) > 0 {
+						fmt.Printf("Error: CRDs without status subresource: %s\n", strings.Join(missing, ", "))
+						exit(1)
+					}
+				}
+
+				if valDefaults {
+					invalid, err := invalidSchemaDefaults(crdMap)
+					if err != nil {
+						fmt.Printf("Error: %v\n", err)
+						exit(1)
+					}
+					if len(invalid) > 0 {
+						fmt.Println("Error: CRD schemas contain invalid defaults:")
+						for _, e := range invalid {
+							fmt.Printf("  - %s\n", e)
+						}
+						exit(1)
+					}
+				}
+
+				// Advisory checks
+				if missing, err := crdsWithoutPrinterColumns(crdMap); err != nil {
+					fmt.Printf("Warning: %v\n", err)
+				} else if len(missing) > 0 {
+					fmt.Println("Advisory: CRDs without additionalPrinterColumns:")
+					for _, name := range missing {
+						fmt.Printf("  - %s\n", name)
+					}
+				}
+
+				if envtest {
+					if err := validateWithEnvtest(envOpts, crdMap); err != nil {
+						fmt.Printf("Error: %v\n", err)
+						exit(1)
+					}
+				}
+
+				validate.End()
+
+				// One chart per targeted Kubernetes version range, or a single chart
+				variants := []crdVariant{{name: newChartName, kubeVersion: ch.Metadata.KubeVersion, crdMap: crdMap}}
+				if len(kubeVersions) > 0 {
+					if variants, err = kubeVersionVariants(newChartName, crdMap, kubeVersions, ch.Metadata.KubeVersion); err != nil {
+						fmt.Printf("Error: %v\n", err)
+						exit(1)
+					}
+				}
+
+				for _, variant := range variants {
+					crdMap, newChartName := variant.crdMap, variant.name
+
+					// Raise the minimum Kubernetes version to the one required by the CRD features
+					if inferKube {
+						minVersion, reasons, err := minKubeVersion(crdMap)
+						if err != nil {
+							fmt.Printf("Error: %v\n", err)
+							exit(1)
+						}
+						if minVersion != "" {
+							variant.kubeVersion = intersectKubeVersions(">="+minVersion+".0-0", variant.kubeVersion)
+							fmt.Printf("Inferred kubeVersion %q for chart %s:\n", variant.kubeVersion, newChartName)
+							for _, reason := range reasons {
+								fmt.Printf("  - %s\n", reason)
+							}
+						}
+					}
+
+					// Convert to slice, or replace crds/ with a Job applying the CRDs
+					var crdFiles []*chart.File
+					if installerJob {
+						if crdFiles, err = instOpts.files(crdMap); err != nil {
+							fmt.Printf("Error generating CRD installer Job: %v\n", err)
+							exit(1)
+						}
+					} else {
+						for _, file := range crdMap {
+							crdFiles = append(crdFiles, file)
+						}
+					}
+					for _, f := range t.nonCRDFiles {
+						if !slices.ContainsFunc(crdFiles, func(c *chart.File) bool { return c.Name == f.Name }) {
+							crdFiles = append(crdFiles, f)
+						}
+					}
+
+					var extraFiles []*chart.File
+
+					var crdList []crdInventoryEntry
+					if inventory || readmeCRDs {
+						if crdList, err = crdInventory(crdMap); err != nil {
+							fmt.Printf("Error: %v\n", err)
+							exit(1)
+						}
+					}
+					if inventory {
+						if !minimal {
+							data, err := crdInventoryFileData(crdList)
+							if err != nil {
+								fmt.Printf("Error generating %s: %v\n", crdInventoryFile, err)
+								exit(1)
+							}
+							extraFiles = append(extraFiles, &chart.File{Name: crdInventoryFile, Data: data})
+						}
+					}
+
+					// Minimal charts contain nothing but Chart.yaml and crds/
+					if !minimal {
+						helpers := referencedHelpers(ch.Templates, crdFiles)
+						for _, f := range copyableFiles(ch) {
+							if t.excludedFiles[f.Name] {
+								continue
+							}
+							if isHelperTemplate(f.Name) && !allHelpers && !helpers[f.Name] {
+								fmt.Printf("Skipping %s (not referenced by the chart templates)\n", f.Name)
+								continue
+							}
+							if f.Name == "doc.yaml" {
+								if data, err := modifyDocYaml(f.Data, newChartName); err != nil {
+									fmt.Printf("Warning: Failed to modify doc.yaml: %v\n", err)
+								} else {
+									extraFiles = append(extraFiles, &chart.File{
+										Name: f.Name,
+										Data: data,
+									})
+								}
+							} else if f.Name == "README.md" && readmeCRDs {
 								extraFiles = append(extraFiles, &chart.File{
 									Name: f.Name,
-									Data: data,
+									Data: injectReadmeCRDSection(f.Data, crdList),
 								})
+							} else {
+								extraFiles = append(extraFiles, f)
 							}
-						} else {
-							extraFiles = append(extraFiles, f)
 						}
-						break
 					}
+
+					// Combine CRDs and extra files, skipping the extra files matched by .helmignore.
+					// CRDs are the content of the chart and always kept.
+					rules, err := ignoreRules(ch)
+					if err != nil {
+						fmt.Printf("Error parsing .helmignore: %v\n", err)
+						exit(1)
+					}
+					var ignored []string
+					extraFiles, ignored = filterIgnored(rules, extraFiles)
+					for _, name := range ignored {
+						fmt.Printf("Skipping %s (matched .helmignore)\n", name)
+					}
+					for _, f := range crdFiles {
+						if isIgnored(rules, f.Name) {
+							fmt.Printf("Warning: keeping CRD file %s although it matches .helmignore\n", f.Name)
+						}
+					}
+					allFiles := append(crdFiles, extraFiles...)
+
+					description := "Chart containing only CRDs from " + strings.Join(t.sources, ", ") + " chart"
+					if len(t.sources) > 1 {
+						description += "s"
+					}
+
+					// Create new minimal chart containing only CRDs
+					newChart := &chart.Chart{
+						Metadata: &chart.Metadata{
+							Name:        newChartName,
+							Version:     ch.Metadata.Version,
+							Description: description,
+							APIVersion:  ch.Metadata.APIVersion,
+							Home:        ch.Metadata.Home,
+							Sources:     ch.Metadata.Sources,
+							Keywords:    ch.Metadata.Keywords,
+							Maintainers: ch.Metadata.Maintainers,
+							Icon:        ch.Metadata.Icon,
+							Condition:   ch.Metadata.Condition,
+							Tags:        ch.Metadata.Tags,
+							AppVersion:  ch.Metadata.AppVersion,
+							Annotations: ch.Metadata.Annotations,
+							KubeVersion: variant.kubeVersion,
+						},
+						Files: allFiles,
+					}
+					if upgradeAPIVersion && newChart.Metadata.APIVersion == chart.APIVersionV1 {
+						newChart.Metadata.APIVersion = chart.APIVersionV2
+						fmt.Printf("Upgraded chart %s to apiVersion %s\n", newChartName, chart.APIVersionV2)
+					}
+					renameChart(newChart, newChartName, mdOpts.nameAnnotationKeys())
+					if semver {
+						newChart.Metadata.Version = strings.TrimPrefix(ch.Metadata.Version, "v")
+					}
+					if err := mdOpts.apply(newChart.Metadata, ch.Metadata); err != nil {
+						fmt.Printf("Error updating chart metadata: %v\n", err)
+						exit(1)
+					}
+					if filled, err := mdOpts.enrich(newChart.Metadata, fileData(ch.Raw, "doc.yaml")); err != nil {
+						fmt.Printf("Error: %v\n", err)
+						exit(1)
+					} else if len(filled) > 0 {
+						fmt.Printf("Filled %s of chart %s from doc.yaml\n", strings.Join(filled, ", "), newChartName)
+					}
+					if inventory {
+						// replaces the annotation inherited from the input chart
+						crds, err := artifactHubCRDs(crdList)
+						if err != nil {
+							fmt.Printf("Error generating %s annotation: %v\n", artifactHubCRDsAnnotation, err)
+							exit(1)
+						}
+						setAnnotation(newChart.Metadata, artifactHubCRDsAnnotation, crds)
+					}
+
+					for key := range crdMap {
+						packaged[key] = true
+					}
+					generated = append(generated, generatedChart{chart: newChart, crdMap: crdMap, extraFiles: len(extraFiles), inputs: t.inputs})
 				}
+				generated = append(generated, generatedChart{inputs: t.inputs})
 			}
 
-			// Save templates helpers
-			for _, f := range ch.Templates {
-				if strings.HasPrefix(f.Name, "templates/_") {
-					extraFiles = append(extraFiles, f)
+			// Nothing is written if CRDs of the baseline are missing
+			if baselineCRDs != nil {
+				if err := checkBaseline(baselineCRDs, packaged, allowRemoval); err != nil {
+					fmt.Printf("Error: %v\n", err)
+					exit(1)
 				}
 			}
 
-			// Combine CRDs and extra files
-			allFiles := append(crdFiles, extraFiles...)
+			for _, g := range generated {
+				if g.chart == nil {
+					recordInputs(g.inputs)
+					continue
+				}
 
-			// Create new minimal chart containing only CRDs
-			newChart := &chart.Chart{
-				Metadata: &chart.Metadata{
-					Name:        newChartName,
-					Version:     ch.Metadata.Version,
-					Description: "Chart containing only CRDs from " + ch.Name() + " chart",
-					APIVersion:  chart.APIVersionV2,
-					Home:        ch.Metadata.Home,
-					Sources:     ch.Metadata.Sources,
-					Keywords:    ch.Metadata.Keywords,
-					Maintainers: ch.Metadata.Maintainers,
-					Icon:        ch.Metadata.Icon,
-					Condition:   ch.Metadata.Condition,
-					Tags:        ch.Metadata.Tags,
-					AppVersion:  ch.Metadata.AppVersion,
-					Annotations: ch.Metadata.Annotations,
-					KubeVersion: ch.Metadata.KubeVersion,
-				},
-				Files: allFiles,
-			}
-			renameChart(newChart, newChartName)
-			if semver {
-				newChart.Metadata.Version = strings.TrimPrefix(ch.Metadata.Version, "v")
-			}
+				// Save to output directory
+				save := startSpan("save", "chart", g.chart.Name())
+				if err := saveChart(g.chart, output); err != nil {
+					fmt.Printf("Error saving repackaged chart: %v\n", err)
+					exit(1)
+				}
+				save.End()
+				reportWritten(filepath.Join(output, g.chart.Name()))
 
-			// Save to output directory
-			if err := chartutil.SaveDir(newChart, output); err != nil {
-				fmt.Printf("Error saving repackaged chart: %v\n", err)
-				os.Exit(1)
-			}
+				if clusterOpts.dryRun {
+					if err := clusterOpts.dryRunApplyCRDs(g.crdMap); err != nil {
+						fmt.Printf("Error: %v\n", err)
+						exit(1)
+					}
+					if err := clusterOpts.dryRunInstall(filepath.Join(output, g.chart.Name()), nil); err != nil {
+						fmt.Printf("Error: %v\n", err)
+						exit(1)
+					}
+				}
 
-			fmt.Printf("Successfully repackaged %d unique CRDs + %d additional files into %s\n",
-				len(crdFiles), len(extraFiles), output)
+				fmt.Printf("Successfully repackaged %d unique CRDs + %d additional files into %s\n",
+					len(g.crdMap), g.extraFiles, output)
+				for _, input := range g.inputs {
+					entries[input].Charts = append(entries[input].Charts, g.chart.Name())
+					for _, key := range sortedCRDKeys(g.crdMap) {
+						entries[input].CRDs = append(entries[input].CRDs, key.String())
+					}
+				}
+			}
 		},
 	}
 
-	cmd.Flags().StringVar(&input, "input", "", "Path to the input Helm chart directory or .tgz file")
+	cmd.Flags().StringSliceVar(&inputs, "input", inputs, "Path or http(s) URL of the input Helm chart directory or .tgz, .tar.zst or .tar file, <repo>/<chart>[@<version>] of a helm repository, oci://<registry>/<repo>/<chart>:<version> or git+https://<repo>//<path>?ref=<ref> (repeatable, CRDs of all inputs are merged)")
 	cmd.Flags().StringVar(&output, "output", "", "Output directory for the repackaged CRDs-only chart")
+	cmd.Flags().StringVar(&name, "name", name, "Name of the generated chart (defaults to <first input chart name>-certified-crds)")
 	cmd.Flags().BoolVar(&semver, "semver", semver, "If true, use strict semver version (no v prefix)")
+	cmd.Flags().BoolVar(&convertCRDs, "convert-crds", convertCRDs, "If true, convert apiextensions.k8s.io/v1beta1 CRDs to apiextensions.k8s.io/v1")
+	cmd.Flags().StringSliceVar(&categories, "crd-category", categories, "Category to add to spec.names.categories of every CRD (repeatable)")
+	cmd.Flags().StringVar(&shortNames, "crd-short-names", shortNames, "Path to a YAML file mapping CRD kind (or kind.group) to the short names to add")
+	cmd.Flags().StringSliceVar(&stripExts, "strip-schema-ext", stripExts, "Schema extension to remove from every CRD schema, e.g. x-kubernetes-validations (repeatable)")
+	cmd.Flags().StringSliceVar(&stripAnns, "strip-crd-annotation", stripAnns, "Glob pattern of CRD annotation keys to remove, e.g. controller-gen.kubebuilder.io/* or *version, where * also matches / (repeatable)")
+	cmd.Flags().StringSliceVar(&stripLabels, "strip-crd-label", stripLabels, "Glob pattern of CRD label keys to remove, where * also matches / (repeatable)")
+	cmd.Flags().StringSliceVar(&skipDeps, "skip-subchart", skipDeps, "Glob pattern of subchart names or aliases whose CRDs are not collected, e.g. cert-manager (repeatable)")
+	cmd.Flags().BoolVar(&perSubchart, "per-subchart", perSubchart, "If true, generate one <dependency>-crds chart per subchart, keeping its version and metadata, instead of a single aggregate chart")
+	cmd.Flags().BoolVar(&pruneDisabled, "prune-disabled", pruneDisabled, "If true, skip subcharts disabled by their dependency condition or tags in the chart values and --values/--set")
+	cmd.Flags().BoolVar(&installerJob, "installer-job", installerJob, "If true, generate a chart whose pre-install/pre-upgrade Job applies the CRDs instead of a crds/ directory, so CRDs are upgraded with the chart")
+	cmd.Flags().StringSliceVar(&kubeVersions, "kube-version-variants", kubeVersions, "Kubernetes versions, e.g. 1.28,1.29, to generate one chart each for, with CRD features unavailable in that version stripped")
+	cmd.Flags().BoolVar(&inferKube, "infer-kube-version", inferKube, "If true, raise kubeVersion of the generated chart to the minimum Kubernetes version supporting the CRD features used, keeping the constraint of the input chart")
+	cmd.Flags().BoolVar(&inventory, "crd-inventory", inventory, "If true, record the packaged CRDs in the artifacthub.io/crds annotation and a crd-inventory.yaml file (skipped with --minimal)")
+	cmd.Flags().BoolVar(&readmeCRDs, "readme-crd-section", readmeCRDs, "If true, add a table of the packaged CRDs to the copied README.md, replacing the content between the "+readmeCRDsStart+" and "+readmeCRDsEnd+" markers if present")
+	cmd.Flags().StringVar(&nonCRDPolicy, "non-crd-files", nonCRDPolicy, "What to do with files under crds/ that are not CRDs, like READMEs or examples: keep, drop or error")
+	cmd.Flags().StringVar(&baseline, "baseline", baseline, "Path or URL of the previously released crd-only chart; the run fails if any of its CRDs is missing from the generated charts")
+	cmd.Flags().StringSliceVar(&allowRemoval, "allow-crd-removal", allowRemoval, "Glob pattern of baseline CRDs, by kind.group or CRD name, that may be missing from the generated charts (repeatable)")
+	cmd.Flags().BoolVar(&interactive, "interactive", interactive, "If true, list the collected CRDs and copyable files of each generated chart and prompt for the ones to include")
+	cmd.Flags().BoolVar(&allHelpers, "copy-all-helpers", allHelpers, "If true, copy every templates/_* helper of the input chart instead of only the ones used by the generated templates")
+	cmd.Flags().BoolVar(&minimal, "minimal", minimal, "If true, only write Chart.yaml and crds/, skipping values, README, helpers and doc.yaml")
+	cmd.Flags().BoolVar(&strict, "strict", strict, "If true, fail if any packaged CRD does not use apiextensions.k8s.io/v1 and refuse input charts without provenance when --verify is set")
+	cmd.Flags().StringVar(&minCRDAPI, "min-crd-api", minCRDAPI, "Fail if any packaged CRD uses an apiextensions.k8s.io version older than this (v1beta1 or v1)")
+	cmd.Flags().BoolVar(&reqStatus, "require-status-subresource", reqStatus, "If true, fail if any served CRD version does not enable the status subresource")
+	cmd.Flags().BoolVar(&valDefaults, "validate-defaults", valDefaults, "If true, fail if a default in a CRD schema does not validate against its own schema")
+	cmd.Flags().BoolVar(&envtest, "validate-with-envtest", envtest, "If true, apply every packaged CRD to a throwaway kube-apiserver started from the envtest binaries, failing on server-side rejections such as structural schema errors, invalid CEL rules or size limits")
+	cmd.Flags().StringVar(&crdTemplates, "crd-templates", crdTemplates, "What to do with template expressions found in crds/ files, which Helm does not render: error, warn or render them using the chart values and --values/--set")
+	cmd.Flags().BoolVar(&renderCRDs, "render-crds", renderCRDs, "If true, render template expressions found in crds/ files using the chart values and --values/--set")
+	_ = cmd.Flags().MarkDeprecated("render-crds", "use --crd-templates=render instead")
+	cmd.Flags().BoolVar(&upgradeAPIVersion, "upgrade-apiversion", upgradeAPIVersion, "If true, generate an apiVersion v2 chart even if the input chart uses apiVersion v1")
+	inOpts.AddFlags(cmd.Flags())
+	valOpts.AddFlags(cmd.Flags())
+	instOpts.AddFlags(cmd.Flags())
+	mdOpts.AddFlags(cmd.Flags())
+	envOpts.AddFlags(cmd.Flags())
+	ckptOpts.AddFlags(cmd.Flags())
+	clusterOpts.AddFlags(cmd.Flags())
 	_ = cobra.MarkFlagRequired(cmd.Flags(), "input")
+	_ = cmd.RegisterFlagCompletionFunc("input", completeChartInput)
 	_ = cobra.MarkFlagRequired(cmd.Flags(), "output")
 
 	return cmd
 }
 
-func collectCRDs(ch *chart.Chart, sourceName string, crdMap map[schema.GroupKind]*chart.File, sourceMap map[schema.GroupKind]string) {
-	for _, f := range ch.CRDObjects() {
-		key, err := extractCRDKey(f.File.Data)
+// collectCRDs adds the CRDs of ch and then of its subcharts to crdMap. CRDs collected earlier take
+// precedence. Messages are written to out. Subcharts whose name matches one of the skip globs are ignored along with their own subcharts.
+func collectCRDs(out io.Writer, ch *chart.Chart, sourceName string, skip []string, crdMap map[schema.GroupKind]*chart.File, sourceMap map[schema.GroupKind]string) error {
+	collectOwnCRDs(out, ch, sourceName, crdMap, sourceMap)
+
+	for _, dep := range ch.Dependencies() {
+		if dep == nil {
+			continue
+		}
+		aliases, err := selectedAliases(ch, dep, skip)
+		if err != nil {
+			return err
+		}
+		if len(aliases) == 0 {
+			fmt.Fprintf(out, "Skipping CRDs of subchart %s/%s\n", sourceName, dep.Name())
+			continue
+		}
+		// Aliases share the same chart and CRDs, which are cluster scoped and installed once
+		if len(aliases) > 1 {
+			fmt.Fprintf(out, "Subchart %s/%s is declared as %s, collecting its CRDs once\n", sourceName, dep.Name(), strings.Join(aliases, ", "))
+		}
+		if err := collectCRDs(out, dep, sourceName+"/"+dependencyLabel(dep, aliases), skip, crdMap, sourceMap); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// collectOwnCRDs adds the CRDs found in the crds/ directory of ch, excluding its subcharts, to crdMap.
+func collectOwnCRDs(out io.Writer, ch *chart.Chart, sourceName string, crdMap map[schema.GroupKind]*chart.File, sourceMap map[schema.GroupKind]string) {
+	var files []*chart.File
+	for _, f := range ch.Files {
+		// other files are reported according to --non-crd-files
+		if !strings.HasPrefix(f.Name, "crds/") || !isCRDFile(f) {
+			continue
+		}
+		items, err := unwrapCRDList(f)
 		if err != nil {
-			fmt.Printf("Warning: Failed to parse CRD %s from %s: %v\n", f.Name, sourceName, err)
+			fmt.Fprintf(out, "Warning: Failed to parse CRD %s from %s: %v\n", f.Name, sourceName, err)
+			continue
+		}
+		if len(items) > 1 || items[0] != f {
+			fmt.Fprintf(out, "Unwrapped %d CRDs from List %s in %s\n", len(items), f.Name, sourceName)
+		}
+		files = append(files, items...)
+	}
+
+	for _, f := range files {
+		key, err := extractCRDKey(f.Data)
+		if err != nil {
+			fmt.Fprintf(out, "Warning: Failed to parse CRD %s from %s: %v\n", f.Name, sourceName, err)
 			continue
 		}
 
 		if existingSource, exists := sourceMap[*key]; exists {
-			fmt.Printf("Warning: CRD %s/%s duplicated in %s — keeping version from %s\n",
-				key.Kind, key.Group, sourceName, existingSource)
+			if sameCRDSpec(crdMap[*key].Data, f.Data) {
+				fmt.Fprintf(out, "Warning: CRD %s/%s duplicated in %s — keeping version from %s\n",
+					key.Kind, key.Group, sourceName, existingSource)
+			} else {
+				fmt.Fprintf(out, "Warning: CRD %s/%s in %s conflicts with the definition in %s — keeping version from %s\n",
+					key.Kind, key.Group, sourceName, existingSource, existingSource)
+			}
 			continue
 		}
 
 		// New unique CRD
-		crdMap[*key] = f.File
+		crdMap[*key] = f
 		sourceMap[*key] = sourceName
 	}
 }
 
+// isManifestFile reports whether name has an extension Helm loads from crds/.
+func isManifestFile(name string) bool {
+	switch path.Ext(name) {
+	case ".yaml", ".yml", ".json":
+		return true
+	}
+	return false
+}
+
+// sameCRDSpec reports whether two CRD manifests have an identical spec.
+func sameCRDSpec(a, b []byte) bool {
+	var x, y map[string]any
+	if err := yaml.Unmarshal(a, &x); err != nil {
+		return false
+	}
+	if err := yaml.Unmarshal(b, &y); err != nil {
+		return false
+	}
+	return reflect.DeepEqual(x["spec"], y["spec"])
+}
+
+// copiedChartFiles are the top-level files of the main chart copied into the CRD chart.
+var copiedChartFiles = []string{
+	"doc.yaml",
+	"README.md",
+	"values.yaml",
+	"values.schema.json",
+	".helmignore",
+}
+
+// copyableFiles returns the files of ch copied into the CRD chart: the top-level
+// documentation and values files, followed by the template helpers.
+func copyableFiles(ch *chart.Chart) []*chart.File {
+	var result []*chart.File
+	for _, name := range copiedChartFiles {
+		if data := fileData(ch.Raw, name); data != nil {
+			result = append(result, &chart.File{Name: name, Data: data})
+		}
+	}
+	for _, f := range ch.Templates {
+		if isHelperTemplate(f.Name) {
+			result = append(result, f)
+		}
+	}
+	return result
+}
+
+// crdTarget describes one CRD chart to generate.
+type crdTarget struct {
+	// chart provides the metadata and extra files of the generated chart
+	chart *chart.Chart
+	name  string
+	// inputs are the --input references the chart is generated from
+	inputs  []string
+	sources []string
+	crdMap  map[schema.GroupKind]*chart.File
+	// nonCRDFiles are the files under crds/ kept by --non-crd-files=keep
+	nonCRDFiles []*chart.File
+	// crdSources records the chart each CRD was collected from
+	crdSources map[schema.GroupKind]string
+	// excludedFiles are the names of the copyable files deselected with --interactive
+	excludedFiles map[string]bool
+}
+
+// generatedChart is a chart generated for a target, written after the checks of the whole run
+// pass. A generatedChart without chart marks the end of the charts of a target.
+type generatedChart struct {
+	chart      *chart.Chart
+	crdMap     map[schema.GroupKind]*chart.File
+	extraFiles int
+	// inputs are the --input references of the target, recorded in the checkpoint
+	inputs []string
+}
+
+// sortedCRDKeys returns the keys of crdMap ordered by group and kind
+func sortedCRDKeys(crdMap map[schema.GroupKind]*chart.File) []schema.GroupKind {
+	keys := make([]schema.GroupKind, 0, len(crdMap))
+	for key := range crdMap {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].Group != keys[j].Group {
+			return keys[i].Group < keys[j].Group
+		}
+		return keys[i].Kind < keys[j].Kind
+	})
+	return keys
+}
+
 // extractCRDKey parses the YAML CRD and builds a unique key
 func extractCRDKey(data []byte) (*schema.GroupKind, error) {
 	var crd crdv1.CustomResourceDefinition
@@ -194,6 +869,42 @@ func extractCRDKey(data []byte) (*schema.GroupKind, error) {
 	}, nil
 }
 
+// unwrapCRDList splits a v1 List of CRDs into one file per item, named after the CRD and placed
+// next to the List file. Any other file is returned as is.
+func unwrapCRDList(f *chart.File) ([]*chart.File, error) {
+	var list struct {
+		APIVersion string           `json:"apiVersion"`
+		Kind       string           `json:"kind"`
+		Items      []map[string]any `json:"items"`
+	}
+	if err := yaml.Unmarshal(f.Data, &list); err != nil {
+		return nil, err
+	}
+	if list.APIVersion != "v1" || list.Kind != "List" {
+		return []*chart.File{f}, nil
+	}
+
+	files := make([]*chart.File, 0, len(list.Items))
+	for i, item := range list.Items {
+		name, _, _ := unstructured.NestedString(item, "metadata", "name")
+		if name == "" {
+			return nil, fmt.Errorf("item %d of List has no name", i)
+		}
+		data, err := yaml.Marshal(item)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, &chart.File{
+			Name: path.Join(path.Dir(f.Name), name+".yaml"),
+			Data: data,
+		})
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("empty List")
+	}
+	return files, nil
+}
+
 // modifyDocYaml replaces common placeholders like {{ .Release.Name }} and {{ .Chart.Name }} with the new fixed name
 func modifyDocYaml(data []byte, newChartName string) ([]byte, error) {
 	var content map[string]any
@@ -215,10 +926,25 @@ func modifyDocYaml(data []byte, newChartName string) ([]byte, error) {
 	return yaml.Marshal(content)
 }
 
-func renameChart(ch *chart.Chart, newChartName string) {
+// fileData returns the content of the named file, or nil if it is not found.
+func fileData(files []*chart.File, name string) []byte {
+	for _, f := range files {
+		if f.Name == name {
+			return f.Data
+		}
+	}
+	return nil
+}
+
+// defaultNameAnnotations are the vendor annotations that always hold the chart name.
+var defaultNameAnnotations = []string{"charts.openshift.io/name"}
+
+// renameChart renames ch, rewriting the nameAnnotations it sets to the new name.
+func renameChart(ch *chart.Chart, newChartName string, nameAnnotations []string) {
 	ch.Metadata.Name = newChartName
-	_, ok := ch.Metadata.Annotations["charts.openshift.io/name"]
-	if ok {
-		ch.Metadata.Annotations["charts.openshift.io/name"] = newChartName
+	for _, key := range nameAnnotations {
+		if _, ok := ch.Metadata.Annotations[key]; ok {
+			setAnnotation(ch.Metadata, key, newChartName)
+		}
 	}
 }
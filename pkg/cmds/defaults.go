@@ -0,0 +1,206 @@
+/*
+Copyright AppsCode Inc. and Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmds
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"math"
+	"reflect"
+	"regexp"
+	"slices"
+	"unicode/utf8"
+
+	"helm.sh/helm/v3/pkg/chart"
+	crdv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// invalidSchemaDefaults checks every default in the CRD schemas against the schema it is declared on.
+// The api server only rejects invalid defaults when the CRD is applied, which breaks chart installs.
+func invalidSchemaDefaults(crdMap map[schema.GroupKind]*chart.File) ([]string, error) {
+	var result []string
+	for _, key := range sortedCRDKeys(crdMap) {
+		crd, err := decodeCRD(crdMap[key].Data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse CRD %s/%s: %v", key.Kind, key.Group, err)
+		}
+		for _, v := range crd.Spec.Versions {
+			if v.Schema == nil || v.Schema.OpenAPIV3Schema == nil {
+				continue
+			}
+			for _, e := range checkSchemaDefaults(v.Schema.OpenAPIV3Schema, "") {
+				result = append(result, fmt.Sprintf("%s/%s %s: %s", key.Kind, key.Group, v.Name, e))
+			}
+		}
+	}
+	return result, nil
+}
+
+// checkSchemaDefaults walks the schema and validates each default against its own schema node.
+func checkSchemaDefaults(s *crdv1.JSONSchemaProps, path string) []string {
+	var errs []string
+	if s.Default != nil {
+		var value any
+		if err := json.Unmarshal(s.Default.Raw, &value); err != nil {
+			errs = append(errs, fmt.Sprintf("%s: invalid default: %v", displayPath(path), err))
+		} else {
+			errs = append(errs, checkValue(s, value, path)...)
+		}
+	}
+	for _, name := range slices.Sorted(maps.Keys(s.Properties)) {
+		prop := s.Properties[name]
+		errs = append(errs, checkSchemaDefaults(&prop, path+"."+name)...)
+	}
+	if s.Items != nil && s.Items.Schema != nil {
+		errs = append(errs, checkSchemaDefaults(s.Items.Schema, path+"[*]")...)
+	}
+	if s.AdditionalProperties != nil && s.AdditionalProperties.Schema != nil {
+		errs = append(errs, checkSchemaDefaults(s.AdditionalProperties.Schema, path+".*")...)
+	}
+	return errs
+}
+
+// checkValue validates value against the type, enum, pattern and bounds of s.
+func checkValue(s *crdv1.JSONSchemaProps, value any, path string) []string {
+	p := displayPath(path)
+	if value == nil {
+		if s.Nullable {
+			return nil
+		}
+		return []string{fmt.Sprintf("%s: default must not be null", p)}
+	}
+
+	if s.XIntOrString {
+		switch v := value.(type) {
+		case string:
+		case float64:
+			if v != math.Trunc(v) {
+				return []string{fmt.Sprintf("%s: default %v must be an integer or string", p, v)}
+			}
+		default:
+			return []string{fmt.Sprintf("%s: default %v must be an integer or string", p, v)}
+		}
+	} else if s.Type != "" {
+		if !matchesType(s.Type, value) {
+			return []string{fmt.Sprintf("%s: default %s must be of type %s", p, jsonString(value), s.Type)}
+		}
+	}
+
+	var errs []string
+	if len(s.Enum) > 0 {
+		found := false
+		for _, e := range s.Enum {
+			var ev any
+			if err := json.Unmarshal(e.Raw, &ev); err == nil && reflect.DeepEqual(ev, value) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			errs = append(errs, fmt.Sprintf("%s: default %s is not one of the enum values", p, jsonString(value)))
+		}
+	}
+
+	switch v := value.(type) {
+	case string:
+		if s.Pattern != "" {
+			// RE2 differs from ECMA-262 in a few corners, skip patterns Go can't compile
+			if re, err := regexp.Compile(s.Pattern); err == nil && !re.MatchString(v) {
+				errs = append(errs, fmt.Sprintf("%s: default %q does not match pattern %q", p, v, s.Pattern))
+			}
+		}
+		n := int64(utf8.RuneCountInString(v))
+		if s.MinLength != nil && n < *s.MinLength {
+			errs = append(errs, fmt.Sprintf("%s: default %q is shorter than %d", p, v, *s.MinLength))
+		}
+		if s.MaxLength != nil && n > *s.MaxLength {
+			errs = append(errs, fmt.Sprintf("%s: default %q is longer than %d", p, v, *s.MaxLength))
+		}
+	case float64:
+		if s.Minimum != nil && (v < *s.Minimum || (s.ExclusiveMinimum && v == *s.Minimum)) {
+			errs = append(errs, fmt.Sprintf("%s: default %v is less than minimum %v", p, v, *s.Minimum))
+		}
+		if s.Maximum != nil && (v > *s.Maximum || (s.ExclusiveMaximum && v == *s.Maximum)) {
+			errs = append(errs, fmt.Sprintf("%s: default %v is greater than maximum %v", p, v, *s.Maximum))
+		}
+	case []any:
+		if s.MinItems != nil && int64(len(v)) < *s.MinItems {
+			errs = append(errs, fmt.Sprintf("%s: default has fewer than %d items", p, *s.MinItems))
+		}
+		if s.MaxItems != nil && int64(len(v)) > *s.MaxItems {
+			errs = append(errs, fmt.Sprintf("%s: default has more than %d items", p, *s.MaxItems))
+		}
+		if s.Items != nil && s.Items.Schema != nil {
+			for i, item := range v {
+				errs = append(errs, checkValue(s.Items.Schema, item, fmt.Sprintf("%s[%d]", path, i))...)
+			}
+		}
+	case map[string]any:
+		for _, name := range s.Required {
+			// the api server applies the defaults of missing properties before validation; those
+			// defaults are checked on their own schema node
+			if prop, ok := s.Properties[name]; ok && prop.Default != nil {
+				continue
+			}
+			if _, ok := v[name]; !ok {
+				errs = append(errs, fmt.Sprintf("%s: default is missing required field %q", p, name))
+			}
+		}
+		for _, name := range slices.Sorted(maps.Keys(v)) {
+			item := v[name]
+			if prop, ok := s.Properties[name]; ok {
+				errs = append(errs, checkValue(&prop, item, path+"."+name)...)
+			} else if s.AdditionalProperties != nil && s.AdditionalProperties.Schema != nil {
+				errs = append(errs, checkValue(s.AdditionalProperties.Schema, item, path+"."+name)...)
+			}
+		}
+	}
+	return errs
+}
+
+func matchesType(typ string, value any) bool {
+	switch v := value.(type) {
+	case string:
+		return typ == "string"
+	case bool:
+		return typ == "boolean"
+	case float64:
+		return typ == "number" || (typ == "integer" && v == math.Trunc(v))
+	case []any:
+		return typ == "array"
+	case map[string]any:
+		return typ == "object"
+	}
+	return false
+}
+
+func displayPath(path string) string {
+	if path == "" {
+		return "<root>"
+	}
+	return path
+}
+
+func jsonString(v any) string {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprintf("%v", v)
+	}
+	return string(data)
+}
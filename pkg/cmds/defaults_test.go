@@ -0,0 +1,114 @@
+/*
+Copyright AppsCode Inc. and Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmds
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	crdv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"k8s.io/utils/ptr"
+)
+
+func TestCheckValue(t *testing.T) {
+	object := func(required []string, props map[string]crdv1.JSONSchemaProps) *crdv1.JSONSchemaProps {
+		return &crdv1.JSONSchemaProps{Type: "object", Required: required, Properties: props}
+	}
+	tests := []struct {
+		name   string
+		schema *crdv1.JSONSchemaProps
+		value  string
+		want   []string
+	}{
+		{
+			name:   "valid string",
+			schema: &crdv1.JSONSchemaProps{Type: "string", Pattern: "^a+$", MaxLength: ptr.To[int64](3)},
+			value:  `"aa"`,
+		},
+		{
+			name:   "wrong type",
+			schema: &crdv1.JSONSchemaProps{Type: "integer"},
+			value:  `1.5`,
+			want:   []string{`.x: default 1.5 must be of type integer`},
+		},
+		{
+			name:   "null not nullable",
+			schema: &crdv1.JSONSchemaProps{Type: "string"},
+			value:  `null`,
+			want:   []string{`.x: default must not be null`},
+		},
+		{
+			name: "not in enum",
+			schema: &crdv1.JSONSchemaProps{Type: "string", Enum: []crdv1.JSON{
+				{Raw: []byte(`"a"`)}, {Raw: []byte(`"b"`)},
+			}},
+			value: `"c"`,
+			want:  []string{`.x: default "c" is not one of the enum values`},
+		},
+		{
+			name:   "int or string",
+			schema: &crdv1.JSONSchemaProps{XIntOrString: true},
+			value:  `"50%"`,
+		},
+		{
+			name:   "below minimum",
+			schema: &crdv1.JSONSchemaProps{Type: "integer", Minimum: ptr.To(1.0)},
+			value:  `0`,
+			want:   []string{`.x: default 0 is less than minimum 1`},
+		},
+		{
+			name: "invalid array item",
+			schema: &crdv1.JSONSchemaProps{Type: "array", Items: &crdv1.JSONSchemaPropsOrArray{
+				Schema: &crdv1.JSONSchemaProps{Type: "string"},
+			}},
+			value: `["a", 1]`,
+			want:  []string{`.x[1]: default 1 must be of type string`},
+		},
+		{
+			name:   "missing required field",
+			schema: object([]string{"port"}, map[string]crdv1.JSONSchemaProps{"port": {Type: "integer"}}),
+			value:  `{}`,
+			want:   []string{`.x: default is missing required field "port"`},
+		},
+		{
+			name: "required field with nested default",
+			schema: object([]string{"port"}, map[string]crdv1.JSONSchemaProps{
+				"port": {Type: "integer", Default: &crdv1.JSON{Raw: []byte(`80`)}},
+			}),
+			value: `{}`,
+		},
+		{
+			name:   "invalid property",
+			schema: object(nil, map[string]crdv1.JSONSchemaProps{"port": {Type: "integer"}}),
+			value:  `{"port": "http"}`,
+			want:   []string{`.x.port: default "http" must be of type integer`},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var value any
+			if err := json.Unmarshal([]byte(tt.value), &value); err != nil {
+				t.Fatal(err)
+			}
+			got := checkValue(tt.schema, value, ".x")
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("checkValue() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,224 @@
+/*
+Copyright AppsCode Inc. and Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmds
+
+import (
+	"fmt"
+	"strings"
+
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/chartutil"
+	"sigs.k8s.io/yaml"
+)
+
+// dependencyAliases returns the names under which parent declares the subchart dep. A chart
+// vendored once may be declared several times with different aliases; the alias (or the chart
+// name if none is set) is the name Helm uses for values and release resources.
+func dependencyAliases(parent, dep *chart.Chart) []string {
+	var names []string
+	if parent.Metadata != nil {
+		for _, d := range parent.Metadata.Dependencies {
+			if d == nil || d.Name != dep.Name() {
+				continue
+			}
+			if d.Alias != "" {
+				names = append(names, d.Alias)
+			} else {
+				names = append(names, d.Name)
+			}
+		}
+	}
+	if len(names) == 0 {
+		names = append(names, dep.Name())
+	}
+	return names
+}
+
+// selectedAliases returns the aliases of dep that match none of the skip globs. Globs are matched
+// against both the alias and the chart name, so skipping a chart name skips all of its aliases.
+func selectedAliases(parent, dep *chart.Chart, skip []string) ([]string, error) {
+	if ok, err := matchAny(skip, dep.Name()); err != nil || ok {
+		return nil, err
+	}
+	var names []string
+	for _, alias := range dependencyAliases(parent, dep) {
+		ok, err := matchAny(skip, alias)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			names = append(names, alias)
+		}
+	}
+	return names, nil
+}
+
+// dependencyLabel describes a subchart by its aliases for log messages, e.g. "primary (alias of sub)".
+func dependencyLabel(dep *chart.Chart, aliases []string) string {
+	label := strings.Join(aliases, ", ")
+	if len(aliases) != 1 || aliases[0] != dep.Name() {
+		label = fmt.Sprintf("%s (alias of %s)", label, dep.Name())
+	}
+	return label
+}
+
+// pruneDisabledDependencies evaluates the condition and tags of the dependencies of ch and its
+// subcharts against vals merged on top of the chart defaults, the same way Helm does at install
+// time. Disabled dependencies are removed from Chart.yaml, and subcharts without any enabled
+// alias are removed from the chart. The paths of the disabled dependencies are returned.
+func pruneDisabledDependencies(ch *chart.Chart, vals map[string]any) ([]string, error) {
+	cvals, err := chartutil.CoalesceValues(ch, vals)
+	if err != nil {
+		return nil, err
+	}
+	// tags are only read from the top level values
+	tags, _ := cvals.Table("tags")
+	return pruneDependencies(ch, ch.Name(), cvals, tags, "")
+}
+
+func pruneDependencies(ch *chart.Chart, chartPath string, cvals, tags chartutil.Values, prefix string) ([]string, error) {
+	var pruned []string
+	declared := map[string]bool{}
+	enabled := map[string]string{} // chart name -> first enabled alias
+	deps := make([]*chart.Dependency, 0, len(ch.Metadata.Dependencies))
+	for _, d := range ch.Metadata.Dependencies {
+		if d == nil {
+			continue
+		}
+		alias := d.Name
+		if d.Alias != "" {
+			alias = d.Alias
+		}
+		declared[d.Name] = true
+		if !dependencyEnabled(d, cvals, tags, prefix) {
+			pruned = append(pruned, chartPath+"/"+alias)
+			continue
+		}
+		deps = append(deps, d)
+		if _, ok := enabled[d.Name]; !ok {
+			enabled[d.Name] = alias
+		}
+	}
+	if len(deps) != len(ch.Metadata.Dependencies) {
+		ch.Metadata.Dependencies = deps
+		if err := pruneLock(ch); err != nil {
+			return nil, err
+		}
+		if err := rewriteRequirements(ch); err != nil {
+			return nil, err
+		}
+	}
+
+	// subcharts not declared in Chart.yaml are always installed by Helm
+	var subcharts []*chart.Chart
+	for _, dep := range ch.Dependencies() {
+		alias, ok := enabled[dep.Name()]
+		if !ok && declared[dep.Name()] {
+			continue
+		}
+		if !ok {
+			alias = dep.Name()
+		}
+		names, err := pruneDependencies(dep, chartPath+"/"+alias, cvals, tags, prefix+alias+".")
+		if err != nil {
+			return nil, err
+		}
+		pruned = append(pruned, names...)
+		subcharts = append(subcharts, dep)
+	}
+	if len(subcharts) != len(ch.Dependencies()) {
+		ch.SetDependencies(subcharts...)
+	}
+	return pruned, nil
+}
+
+// dependencyEnabled follows Helm: a dependency is disabled by tags if all of its tags that are set
+// are false, and the first condition path that resolves to a boolean overrides the tags.
+func dependencyEnabled(d *chart.Dependency, cvals, tags chartutil.Values, prefix string) bool {
+	enabled := true
+	var hasTrue, hasFalse bool
+	for _, tag := range d.Tags {
+		if v, ok := tags[tag].(bool); ok {
+			hasTrue = hasTrue || v
+			hasFalse = hasFalse || !v
+		}
+	}
+	if hasFalse && !hasTrue {
+		enabled = false
+	}
+	for _, c := range strings.Split(strings.TrimSpace(d.Condition), ",") {
+		if c = strings.TrimSpace(c); c == "" {
+			continue
+		}
+		if v, err := cvals.PathValue(prefix + c); err == nil {
+			if b, ok := v.(bool); ok {
+				return b
+			}
+			fmt.Printf("Warning: condition path %q for dependency %s returned non-bool value\n", prefix+c, d.Name)
+		}
+	}
+	return enabled
+}
+
+// pruneLock drops the lock entries of dependencies no longer declared in Chart.yaml and recomputes the digest.
+func pruneLock(ch *chart.Chart) error {
+	if ch.Lock == nil {
+		return nil
+	}
+	names := map[string]bool{}
+	for _, d := range ch.Metadata.Dependencies {
+		names[d.Name] = true
+	}
+	deps := make([]*chart.Dependency, 0, len(ch.Lock.Dependencies))
+	for _, d := range ch.Lock.Dependencies {
+		if names[d.Name] {
+			deps = append(deps, d)
+		}
+	}
+	ch.Lock.Dependencies = deps
+	digest, err := hashReq(ch.Metadata.Dependencies, ch.Lock.Dependencies)
+	if err != nil {
+		return err
+	}
+	ch.Lock.Digest = digest
+	return nil
+}
+
+// rewriteRequirements updates the requirements.yaml and requirements.lock files of an apiVersion v1
+// chart from its dependencies and lock, since chartutil.SaveDir writes them back from the chart files.
+func rewriteRequirements(ch *chart.Chart) error {
+	if ch.Metadata.APIVersion != chart.APIVersionV1 {
+		return nil
+	}
+	for i, f := range ch.Files {
+		var v any
+		switch {
+		case f.Name == requirementsFile:
+			v = map[string]any{"dependencies": ch.Metadata.Dependencies}
+		case f.Name == requirementsLockFile && ch.Lock != nil:
+			v = ch.Lock
+		default:
+			continue
+		}
+		data, err := yaml.Marshal(v)
+		if err != nil {
+			return err
+		}
+		ch.Files[i] = &chart.File{Name: f.Name, Data: data}
+	}
+	return nil
+}
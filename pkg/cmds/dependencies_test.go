@@ -0,0 +1,145 @@
+/*
+Copyright AppsCode Inc. and Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmds
+
+import (
+	"reflect"
+	"testing"
+
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/chartutil"
+	"sigs.k8s.io/yaml"
+)
+
+func TestDependencyEnabled(t *testing.T) {
+	tests := []struct {
+		name string
+		dep  chart.Dependency
+		vals string
+		want bool
+	}{
+		{name: "no condition or tags", dep: chart.Dependency{Name: "sub"}, want: true},
+		{name: "condition true", dep: chart.Dependency{Name: "sub", Condition: "sub.enabled"}, vals: "sub: {enabled: true}", want: true},
+		{name: "condition false", dep: chart.Dependency{Name: "sub", Condition: "sub.enabled"}, vals: "sub: {enabled: false}", want: false},
+		{name: "condition unset", dep: chart.Dependency{Name: "sub", Condition: "sub.enabled"}, want: true},
+		{name: "first resolved condition wins", dep: chart.Dependency{Name: "sub", Condition: "a.enabled, b.enabled"}, vals: "b: {enabled: false}", want: false},
+		{name: "all tags false", dep: chart.Dependency{Name: "sub", Tags: []string{"foo", "bar"}}, vals: "tags: {foo: false, bar: false}", want: false},
+		{name: "one tag true", dep: chart.Dependency{Name: "sub", Tags: []string{"foo", "bar"}}, vals: "tags: {foo: false, bar: true}", want: true},
+		{name: "condition overrides tags", dep: chart.Dependency{Name: "sub", Condition: "sub.enabled", Tags: []string{"x"}}, vals: "tags: {x: false}\nsub: {enabled: true}", want: true},
+		{name: "prefixed condition", dep: chart.Dependency{Name: "sub", Condition: "sub.enabled"}, vals: "parent: {sub: {enabled: false}}", want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cvals := chartutil.Values{}
+			if err := yaml.Unmarshal([]byte(tt.vals), &cvals); err != nil {
+				t.Fatal(err)
+			}
+			tags, _ := cvals.Table("tags")
+			if got := dependencyEnabled(&tt.dep, cvals, tags, ""); got != tt.want {
+				t.Errorf("dependencyEnabled() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// testChartWithDependencies returns a chart with the subcharts a, b and c, enabled by <name>.enabled.
+func testChartWithDependencies(apiVersion string) *chart.Chart {
+	ch := &chart.Chart{Metadata: &chart.Metadata{APIVersion: apiVersion, Name: "parent", Version: "0.1.0"}}
+	ch.Lock = &chart.Lock{}
+	for _, name := range []string{"a", "b", "c"} {
+		ch.Metadata.Dependencies = append(ch.Metadata.Dependencies, &chart.Dependency{Name: name, Version: "1.0.0", Condition: name + ".enabled"})
+		ch.Lock.Dependencies = append(ch.Lock.Dependencies, &chart.Dependency{Name: name, Version: "1.0.0"})
+		ch.AddDependency(&chart.Chart{Metadata: &chart.Metadata{APIVersion: apiVersion, Name: name, Version: "1.0.0"}})
+	}
+	if apiVersion == chart.APIVersionV1 {
+		ch.Files = []*chart.File{{Name: requirementsFile}, {Name: requirementsLockFile}}
+	}
+	return ch
+}
+
+func TestPruneDisabledDependencies(t *testing.T) {
+	tests := []struct {
+		name       string
+		apiVersion string
+		vals       map[string]any
+		wantPruned []string
+		wantDeps   []string
+	}{
+		{
+			name:       "all enabled",
+			apiVersion: chart.APIVersionV2,
+			wantDeps:   []string{"a", "b", "c"},
+		},
+		{
+			name:       "v2 disabled",
+			apiVersion: chart.APIVersionV2,
+			vals:       map[string]any{"b": map[string]any{"enabled": false}},
+			wantPruned: []string{"parent/b"},
+			wantDeps:   []string{"a", "c"},
+		},
+		{
+			name:       "v1 disabled",
+			apiVersion: chart.APIVersionV1,
+			vals:       map[string]any{"a": map[string]any{"enabled": false}, "c": map[string]any{"enabled": false}},
+			wantPruned: []string{"parent/a", "parent/c"},
+			wantDeps:   []string{"b"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ch := testChartWithDependencies(tt.apiVersion)
+			pruned, err := pruneDisabledDependencies(ch, tt.vals)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(pruned, tt.wantPruned) {
+				t.Errorf("pruned = %v, want %v", pruned, tt.wantPruned)
+			}
+			var subcharts, declared, locked []string
+			for _, dep := range ch.Dependencies() {
+				subcharts = append(subcharts, dep.Name())
+			}
+			for _, d := range ch.Metadata.Dependencies {
+				declared = append(declared, d.Name)
+			}
+			for _, d := range ch.Lock.Dependencies {
+				locked = append(locked, d.Name)
+			}
+			for what, got := range map[string][]string{"subcharts": subcharts, "dependencies": declared, "lock": locked} {
+				if !reflect.DeepEqual(got, tt.wantDeps) {
+					t.Errorf("%s = %v, want %v", what, got, tt.wantDeps)
+				}
+			}
+
+			for _, f := range ch.Files {
+				var content struct {
+					Dependencies []*chart.Dependency `json:"dependencies"`
+				}
+				if err := yaml.Unmarshal(f.Data, &content); err != nil {
+					t.Fatal(err)
+				}
+				var names []string
+				for _, d := range content.Dependencies {
+					names = append(names, d.Name)
+				}
+				if len(pruned) > 0 && !reflect.DeepEqual(names, tt.wantDeps) {
+					t.Errorf("%s dependencies = %v, want %v", f.Name, names, tt.wantDeps)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,227 @@
+/*
+Copyright AppsCode Inc. and Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmds
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"helm.sh/helm/v3/pkg/chart/loader"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	clusterProviderKind = "kind"
+	clusterProviderK3d  = "k3d"
+)
+
+// e2eStep is the outcome of a single step run by the e2e command.
+type e2eStep struct {
+	name     string
+	duration time.Duration
+	err      error
+}
+
+func NewCmdE2E() *cobra.Command {
+	var (
+		crdOnly     string
+		crdLess     string
+		provider    = clusterProviderKind
+		clusterName string
+		nodeImage   string
+		namespace   = "default"
+		timeout     = 5 * time.Minute
+		helmTest    bool
+		keepCluster bool
+		valOpts     valuesOptions
+	)
+	cmd := &cobra.Command{
+		Use:                   "e2e",
+		Short:                 "Install the generated crd-only and crd-less charts into a throwaway kind or k3d cluster",
+		DisableFlagsInUseLine: true,
+		DisableAutoGenTag:     true,
+		Run: func(cmd *cobra.Command, args []string) {
+			if provider != clusterProviderKind && provider != clusterProviderK3d {
+				fmt.Printf("Error: unsupported cluster provider %q, use %s or %s\n", provider, clusterProviderKind, clusterProviderK3d)
+				exit(1)
+			}
+			vals, err := valOpts.merge()
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
+				exit(1)
+			}
+			crdChart, err := loader.Load(crdOnly)
+			if err != nil {
+				fmt.Printf("Error loading crd-only chart: %v\n", err)
+				exit(1)
+			}
+			certifiedChart, err := loader.Load(crdLess)
+			if err != nil {
+				fmt.Printf("Error loading crd-less chart: %v\n", err)
+				exit(1)
+			}
+			if clusterName == "" {
+				clusterName = fmt.Sprintf("chart-packer-e2e-%d", time.Now().Unix())
+			}
+
+			dir, err := os.MkdirTemp("", "chart-packer-e2e-")
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
+				exit(1)
+			}
+			defer func() { _ = os.RemoveAll(dir) }()
+			kubeconfig := filepath.Join(dir, "kubeconfig")
+			valuesFile := filepath.Join(dir, "values.yaml")
+			data, err := yaml.Marshal(vals)
+			if err == nil {
+				err = os.WriteFile(valuesFile, data, 0o600)
+			}
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
+				exit(1)
+			}
+
+			helmArgs := func(args ...string) []string {
+				return append(args, "--kubeconfig", kubeconfig, "--namespace", namespace)
+			}
+			installArgs := func(release, chartPath string, extra ...string) []string {
+				args := helmArgs("install", release, chartPath, "--create-namespace", "--wait", "--timeout", timeout.String())
+				return append(args, extra...)
+			}
+
+			var steps []e2eStep
+			run := func(name string, fn func() error) bool {
+				s := startSpan("e2e", "step", name)
+				start := time.Now()
+				err := fn()
+				s.End()
+				steps = append(steps, e2eStep{name: name, duration: time.Since(start), err: err})
+				return err == nil
+			}
+
+			created := run(fmt.Sprintf("create %s cluster %s", provider, clusterName), func() error {
+				return createE2ECluster(provider, clusterName, nodeImage, kubeconfig)
+			})
+			ok := created
+			if ok {
+				ok = run("install crd-only chart "+crdChart.Name(), func() error {
+					return runTool("helm", installArgs(crdChart.Name(), crdOnly)...)
+				})
+			}
+			if ok {
+				ok = run("install crd-less chart "+certifiedChart.Name(), func() error {
+					return runTool("helm", installArgs(certifiedChart.Name(), crdLess, "--values", valuesFile)...)
+				})
+			}
+			if ok && helmTest {
+				run("test crd-less chart "+certifiedChart.Name(), func() error {
+					return runTool("helm", helmArgs("test", certifiedChart.Name(), "--timeout", timeout.String())...)
+				})
+			}
+			switch {
+			case !created:
+			case keepCluster:
+				// The kubeconfig must outlive the temporary directory to use the cluster
+				if err := os.Rename(kubeconfig, clusterName+".kubeconfig"); err == nil {
+					fmt.Printf("Keeping cluster %s, kubeconfig: %s.kubeconfig\n", clusterName, clusterName)
+				}
+			default:
+				run(fmt.Sprintf("delete %s cluster %s", provider, clusterName), func() error {
+					return deleteE2ECluster(provider, clusterName, kubeconfig)
+				})
+			}
+
+			failed := 0
+			for _, s := range steps {
+				status := "PASS"
+				if s.err != nil {
+					status = "FAIL"
+					failed++
+				}
+				fmt.Printf("%s  %s (%s)\n", status, s.name, s.duration.Round(time.Second))
+				if s.err != nil {
+					fmt.Printf("        - %s\n", strings.ReplaceAll(strings.TrimSpace(s.err.Error()), "\n", "\n          "))
+				}
+			}
+			if failed > 0 {
+				fmt.Printf("%d of %d steps failed\n", failed, len(steps))
+				_ = os.RemoveAll(dir)
+				exit(1)
+			}
+		},
+	}
+
+	cmd.Flags().StringVar(&crdOnly, "crd-only", crdOnly, "Path of the generated crd-only chart directory or .tgz file")
+	cmd.Flags().StringVar(&crdLess, "crd-less", crdLess, "Path of the generated crd-less chart directory or .tgz file")
+	cmd.Flags().StringVar(&provider, "provider", provider, "Tool used to create the cluster, kind or k3d")
+	cmd.Flags().StringVar(&clusterName, "cluster-name", clusterName, "Name of the throwaway cluster (defaults to chart-packer-e2e-<timestamp>)")
+	cmd.Flags().StringVar(&nodeImage, "image", nodeImage, "Node image of the cluster, e.g. kindest/node:v1.30.0 or rancher/k3s:v1.30.0-k3s1")
+	cmd.Flags().StringVar(&namespace, "namespace", namespace, "Namespace to install the charts into")
+	cmd.Flags().DurationVar(&timeout, "timeout", timeout, "Time to wait for each chart to become ready")
+	cmd.Flags().BoolVar(&helmTest, "helm-test", helmTest, "If true, run the tests of the crd-less chart after installing it")
+	cmd.Flags().BoolVar(&keepCluster, "keep-cluster", keepCluster, "If true, do not delete the cluster and write its kubeconfig to <cluster-name>.kubeconfig")
+	valOpts.AddFlags(cmd.Flags())
+	_ = cobra.MarkFlagRequired(cmd.Flags(), "crd-only")
+	_ = cobra.MarkFlagRequired(cmd.Flags(), "crd-less")
+
+	return cmd
+}
+
+// createE2ECluster creates a kind or k3d cluster, writing its kubeconfig to kubeconfig
+// instead of the user's default kubeconfig.
+func createE2ECluster(provider, name, image, kubeconfig string) error {
+	switch provider {
+	case clusterProviderK3d:
+		args := []string{"cluster", "create", name, "--wait", "--kubeconfig-update-default=false", "--kubeconfig-switch-context=false"}
+		if image != "" {
+			args = append(args, "--image", image)
+		}
+		if err := runTool("k3d", args...); err != nil {
+			return err
+		}
+		return runTool("k3d", "kubeconfig", "write", name, "--output", kubeconfig)
+	default:
+		args := []string{"create", "cluster", "--name", name, "--kubeconfig", kubeconfig, "--wait", "2m"}
+		if image != "" {
+			args = append(args, "--image", image)
+		}
+		return runTool("kind", args...)
+	}
+}
+
+func deleteE2ECluster(provider, name, kubeconfig string) error {
+	if provider == clusterProviderK3d {
+		return runTool("k3d", "cluster", "delete", name)
+	}
+	return runTool("kind", "delete", "cluster", "--name", name, "--kubeconfig", kubeconfig)
+}
+
+// runTool runs an external command, streaming its output to stderr.
+func runTool(name string, args ...string) error {
+	c := exec.Command(name, args...)
+	c.Stdout = os.Stderr
+	c.Stderr = os.Stderr
+	if err := c.Run(); err != nil {
+		return fmt.Errorf("%s %s: %v", name, strings.Join(args[:min(2, len(args))], " "), err)
+	}
+	return nil
+}
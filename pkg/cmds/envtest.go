@@ -0,0 +1,380 @@
+/*
+Copyright AppsCode Inc. and Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmds
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/pflag"
+	"helm.sh/helm/v3/pkg/chart"
+	crdv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/rest"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	envtestToken        = "chart-packer-envtest"
+	envtestStartTimeout = time.Minute
+	crdEstablishTimeout = 30 * time.Second
+)
+
+var crdResource = crdv1.SchemeGroupVersion.WithResource("customresourcedefinitions")
+
+// envtestOptions locates the etcd and kube-apiserver binaries used to validate CRDs.
+type envtestOptions struct {
+	assets string
+}
+
+func newEnvtestOptions() envtestOptions {
+	return envtestOptions{
+		assets: os.Getenv("KUBEBUILDER_ASSETS"),
+	}
+}
+
+func (o *envtestOptions) AddFlags(fs *pflag.FlagSet) {
+	fs.StringVar(&o.assets, "envtest-assets", o.assets, "Directory containing the etcd and kube-apiserver binaries, as installed by setup-envtest (defaults to $KUBEBUILDER_ASSETS)")
+}
+
+// testControlPlane is a throwaway etcd and kube-apiserver, started from the binaries installed by
+// setup-envtest, the same way controller-runtime's envtest does.
+type testControlPlane struct {
+	dir       string
+	etcd      *exec.Cmd
+	apiserver *exec.Cmd
+	config    *rest.Config
+}
+
+// start starts a test control plane. The caller must stop it.
+func (o *envtestOptions) start() (*testControlPlane, error) {
+	if o.assets == "" {
+		return nil, fmt.Errorf("--envtest-assets or KUBEBUILDER_ASSETS must point to the etcd and kube-apiserver binaries, e.g. $(setup-envtest use -p path)")
+	}
+	dir, err := os.MkdirTemp("", "chart-packer-envtest-")
+	if err != nil {
+		return nil, err
+	}
+	cp := &testControlPlane{dir: dir}
+	if err := cp.start(o.assets); err != nil {
+		cp.stop()
+		return nil, err
+	}
+	return cp, nil
+}
+
+func (cp *testControlPlane) start(assets string) error {
+	ports, err := freePorts(3)
+	if err != nil {
+		return err
+	}
+	etcdURL := "http://127.0.0.1:" + strconv.Itoa(ports[0])
+	cp.etcd = exec.Command(filepath.Join(assets, "etcd"),
+		"--data-dir", filepath.Join(cp.dir, "etcd"),
+		"--listen-client-urls", etcdURL,
+		"--advertise-client-urls", etcdURL,
+		"--listen-peer-urls", "http://127.0.0.1:"+strconv.Itoa(ports[1]),
+		"--unsafe-no-fsync",
+	)
+	if err := cp.run(cp.etcd, "etcd.log"); err != nil {
+		return err
+	}
+
+	caPEM, err := cp.writeCertificates()
+	if err != nil {
+		return err
+	}
+	tokens := filepath.Join(cp.dir, "tokens.csv")
+	if err := os.WriteFile(tokens, []byte(envtestToken+",admin,admin,system:masters\n"), 0o600); err != nil {
+		return err
+	}
+	cp.apiserver = exec.Command(filepath.Join(assets, "kube-apiserver"),
+		"--etcd-servers", etcdURL,
+		"--bind-address", "127.0.0.1",
+		"--advertise-address", "127.0.0.1",
+		"--secure-port", strconv.Itoa(ports[2]),
+		"--tls-cert-file", filepath.Join(cp.dir, "apiserver.crt"),
+		"--tls-private-key-file", filepath.Join(cp.dir, "apiserver.key"),
+		"--token-auth-file", tokens,
+		"--authorization-mode", "RBAC",
+		"--service-cluster-ip-range", "10.0.0.0/24",
+		"--service-account-issuer", "https://kubernetes.default.svc",
+		"--service-account-key-file", filepath.Join(cp.dir, "sa.key"),
+		"--service-account-signing-key-file", filepath.Join(cp.dir, "sa.key"),
+		"--disable-admission-plugins", "ServiceAccount",
+		"--allow-privileged",
+	)
+	if err := cp.run(cp.apiserver, "kube-apiserver.log"); err != nil {
+		return err
+	}
+
+	cp.config = &rest.Config{
+		Host:            "https://127.0.0.1:" + strconv.Itoa(ports[2]),
+		BearerToken:     envtestToken,
+		TLSClientConfig: rest.TLSClientConfig{CAData: caPEM},
+	}
+	return cp.waitReady(caPEM)
+}
+
+// run starts c with its output written to a log file of the control plane directory.
+func (cp *testControlPlane) run(c *exec.Cmd, logFile string) error {
+	log, err := os.Create(filepath.Join(cp.dir, logFile))
+	if err != nil {
+		return err
+	}
+	c.Stdout = log
+	c.Stderr = log
+	if err := c.Start(); err != nil {
+		return fmt.Errorf("failed to start %s: %v", filepath.Base(c.Path), err)
+	}
+	return nil
+}
+
+// waitReady polls the readyz endpoint of the kube-apiserver.
+func (cp *testControlPlane) waitReady(caPEM []byte) error {
+	pool := x509.NewCertPool()
+	pool.AppendCertsFromPEM(caPEM)
+	client := &http.Client{
+		Timeout:   5 * time.Second,
+		Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: pool}},
+	}
+	deadline := time.Now().Add(envtestStartTimeout)
+	for time.Now().Before(deadline) {
+		req, err := http.NewRequest(http.MethodGet, cp.config.Host+"/readyz", nil)
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Authorization", "Bearer "+envtestToken)
+		if resp, err := client.Do(req); err == nil {
+			_ = resp.Body.Close()
+			if resp.StatusCode == http.StatusOK {
+				return nil
+			}
+		}
+		time.Sleep(500 * time.Millisecond)
+	}
+	logs, _ := os.ReadFile(filepath.Join(cp.dir, "kube-apiserver.log"))
+	if len(logs) > 2048 {
+		logs = logs[len(logs)-2048:]
+	}
+	return fmt.Errorf("kube-apiserver did not become ready within %s:\n%s", envtestStartTimeout, logs)
+}
+
+// writeCertificates writes the serving certificate of the kube-apiserver, signed by a new CA,
+// and the service account signing key. It returns the CA certificate.
+func (cp *testControlPlane) writeCertificates() ([]byte, error) {
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	ca := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "chart-packer-envtest-ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(24 * time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, ca, ca, &caKey.PublicKey, caKey)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	serving := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "kube-apiserver"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(24 * time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		DNSNames:     []string{"localhost"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, serving, ca, &key.PublicKey, caKey)
+	if err != nil {
+		return nil, err
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+	saKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	saDER, err := x509.MarshalECPrivateKey(saKey)
+	if err != nil {
+		return nil, err
+	}
+
+	caPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: caDER})
+	for name, data := range map[string][]byte{
+		"apiserver.crt": pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		"apiserver.key": pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}),
+		"sa.key":        pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: saDER}),
+	} {
+		if err := os.WriteFile(filepath.Join(cp.dir, name), data, 0o600); err != nil {
+			return nil, err
+		}
+	}
+	return caPEM, nil
+}
+
+// stop terminates the control plane processes and removes their data.
+func (cp *testControlPlane) stop() {
+	for _, c := range []*exec.Cmd{cp.apiserver, cp.etcd} {
+		if c != nil && c.Process != nil {
+			_ = c.Process.Kill()
+			_ = c.Wait()
+		}
+	}
+	_ = os.RemoveAll(cp.dir)
+}
+
+// applyCRDs creates or updates the CRDs of crdMap with server-side apply and waits for them to be
+// established, unless dryRun is set. The server-side rejections are returned, one entry per CRD.
+func applyCRDs(config *rest.Config, crdMap map[schema.GroupKind]*chart.File, dryRun bool) ([]string, error) {
+	client, err := dynamic.NewForConfig(config)
+	if err != nil {
+		return nil, err
+	}
+	crds := client.Resource(crdResource)
+	ctx := context.Background()
+
+	var rejected []string
+	for _, key := range sortedCRDKeys(crdMap) {
+		data, _, err := convertCRDToV1(crdMap[key].Data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to convert CRD %s/%s to v1: %v", key.Kind, key.Group, err)
+		}
+		var obj unstructured.Unstructured
+		if err := yaml.Unmarshal(data, &obj.Object); err != nil {
+			return nil, fmt.Errorf("failed to parse CRD %s/%s: %v", key.Kind, key.Group, err)
+		}
+		opts := metav1.ApplyOptions{FieldManager: "chart-packer", Force: true}
+		if dryRun {
+			opts.DryRun = []string{metav1.DryRunAll}
+		}
+		if _, err := crds.Apply(ctx, obj.GetName(), &obj, opts); err != nil {
+			var status apierrors.APIStatus
+			if !errors.As(err, &status) {
+				return nil, err
+			}
+			rejected = append(rejected, fmt.Sprintf("%s/%s: %v", key.Kind, key.Group, err))
+			continue
+		}
+		if dryRun {
+			continue
+		}
+		if err := waitCRDEstablished(ctx, client, obj.GetName()); err != nil {
+			rejected = append(rejected, fmt.Sprintf("%s/%s: %v", key.Kind, key.Group, err))
+		}
+	}
+	return rejected, nil
+}
+
+// waitCRDEstablished waits for the Established condition of the named CRD, reporting
+// the NamesAccepted condition message if the names conflict.
+func waitCRDEstablished(ctx context.Context, client dynamic.Interface, name string) error {
+	deadline := time.Now().Add(crdEstablishTimeout)
+	for {
+		obj, err := client.Resource(crdResource).Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		conditions, _, _ := unstructured.NestedSlice(obj.Object, "status", "conditions")
+		for _, c := range conditions {
+			cond, _ := c.(map[string]any)
+			switch {
+			case cond["type"] == string(crdv1.Established) && cond["status"] == string(crdv1.ConditionTrue):
+				return nil
+			case cond["type"] == string(crdv1.NamesAccepted) && cond["status"] == string(crdv1.ConditionFalse):
+				return fmt.Errorf("names not accepted: %v", cond["message"])
+			}
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("not established within %s", crdEstablishTimeout)
+		}
+		time.Sleep(200 * time.Millisecond)
+	}
+}
+
+// freePorts returns n distinct free local TCP ports.
+func freePorts(n int) ([]int, error) {
+	var ports []int
+	var listeners []net.Listener
+	defer func() {
+		for _, l := range listeners {
+			_ = l.Close()
+		}
+	}()
+	for i := 0; i < n; i++ {
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			return nil, err
+		}
+		listeners = append(listeners, l)
+		ports = append(ports, l.Addr().(*net.TCPAddr).Port)
+	}
+	return ports, nil
+}
+
+// validateWithEnvtest applies the CRDs of crdMap to a test control plane, which is stopped
+// before returning, and fails listing the CRDs rejected by the kube-apiserver.
+func validateWithEnvtest(o envtestOptions, crdMap map[schema.GroupKind]*chart.File) error {
+	cp, err := o.start()
+	if err != nil {
+		return fmt.Errorf("failed to start envtest control plane: %v", err)
+	}
+	defer cp.stop()
+
+	rejected, err := applyCRDs(cp.config, crdMap, false)
+	if err != nil {
+		return err
+	}
+	if len(rejected) > 0 {
+		return fmt.Errorf("kube-apiserver rejected CRDs:\n  - %s", strings.Join(rejected, "\n  - "))
+	}
+	fmt.Printf("Applied %d CRDs to envtest kube-apiserver\n", len(crdMap))
+	return nil
+}
@@ -0,0 +1,287 @@
+/*
+Copyright AppsCode Inc. and Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmds
+
+import (
+	"fmt"
+	"maps"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"helm.sh/helm/v3/pkg/chart"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// extractKind selects the rendered objects, and so the templates, that make up an extracted chart.
+type extractKind struct {
+	suffix      string
+	description string
+	selectFn    func(manifests []renderedManifest) []renderedManifest
+}
+
+var extractKinds = map[string]extractKind{
+	"webhook": {
+		suffix:      "webhooks",
+		description: "admission webhook configurations",
+		selectFn:    selectWebhooks,
+	},
+}
+
+func NewCmdExtract() *cobra.Command {
+	var (
+		input   string
+		output  string
+		kind    string
+		semver  = true
+		mdOpts  = newMetadataOptions()
+		inOpts  = newInputOptions()
+		valOpts valuesOptions
+	)
+	cmd := &cobra.Command{
+		Use:                   "extract",
+		Short:                 "Extract templates of a kind of resources into a separate chart",
+		Long:                  "Moves the templates rendering a kind of resources into a <chart>-<kind> chart, and writes the other templates into a <chart>-without-<kind> chart.",
+		DisableFlagsInUseLine: true,
+		DisableAutoGenTag:     true,
+		Run: func(cmd *cobra.Command, args []string) {
+			ek, ok := extractKinds[kind]
+			if !ok {
+				fmt.Printf("Error: unsupported kind %q, supported kinds: %s\n", kind, strings.Join(slices.Sorted(maps.Keys(extractKinds)), ", "))
+				exit(1)
+			}
+
+			vals, err := valOpts.merge()
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
+				exit(1)
+			}
+			ch, err := inOpts.load(input, false)
+			if err != nil {
+				fmt.Printf("Error loading chart: %v\n", err)
+				exit(1)
+			}
+			newChartName := ch.Metadata.Name + "-" + ek.suffix
+
+			// Render the chart to find out which templates produce the selected objects
+			manifests, err := renderManifests(ch, vals, defaultReleaseOptions(ch))
+			if err != nil {
+				fmt.Printf("Error rendering chart: %v\n", err)
+				exit(1)
+			}
+			picked := map[*unstructured.Unstructured]bool{}
+			sources := map[string]bool{}
+			for _, m := range ek.selectFn(manifests) {
+				picked[m.obj] = true
+				sources[m.source] = true
+			}
+			if len(sources) == 0 {
+				fmt.Printf("Error: chart %s does not render any %s\n", ch.Name(), ek.description)
+				exit(1)
+			}
+
+			// Copy the whole template file producing a selected object, along with the helpers
+			prefix := ch.Name() + "/"
+			var templates []*chart.File
+			extracted := map[string]bool{}
+			for _, f := range ch.Templates {
+				if strings.HasPrefix(path.Base(f.Name), "_") {
+					templates = append(templates, f)
+				} else if sources[prefix+f.Name] {
+					templates = append(templates, f)
+					extracted[prefix+f.Name] = true
+					delete(sources, prefix+f.Name)
+					fmt.Printf("Extracting %s\n", f.Name)
+				}
+			}
+			for _, m := range manifests {
+				if extracted[m.source] && !picked[m.obj] {
+					fmt.Printf("Warning: %s also renders %s %s\n", strings.TrimPrefix(m.source, prefix), m.obj.GetKind(), m.obj.GetName())
+				}
+			}
+			for _, source := range slices.Sorted(maps.Keys(sources)) {
+				fmt.Printf("Warning: skipping %s, templates of subcharts can not be extracted\n", source)
+			}
+
+			var files []*chart.File
+			for _, f := range ch.Raw {
+				if f.Name == "values.yaml" || f.Name == "values.schema.json" || f.Name == ".helmignore" {
+					files = append(files, f)
+				}
+			}
+
+			newChart := &chart.Chart{
+				Metadata: &chart.Metadata{
+					Name:        newChartName,
+					Version:     ch.Metadata.Version,
+					Description: "Chart containing only " + ek.description + " from " + ch.Name() + " chart",
+					APIVersion:  ch.Metadata.APIVersion,
+					Home:        ch.Metadata.Home,
+					Sources:     ch.Metadata.Sources,
+					Keywords:    ch.Metadata.Keywords,
+					Maintainers: ch.Metadata.Maintainers,
+					Icon:        ch.Metadata.Icon,
+					AppVersion:  ch.Metadata.AppVersion,
+					Annotations: ch.Metadata.Annotations,
+					KubeVersion: ch.Metadata.KubeVersion,
+				},
+				Templates: templates,
+				Files:     files,
+			}
+
+			// Both charts get the metadata flags, like the charts generated by crd-only and crd-less
+			updateMetadata := func(c *chart.Chart, name string, src *chart.Metadata) {
+				renameChart(c, name, mdOpts.nameAnnotationKeys())
+				if semver {
+					c.Metadata.Version = strings.TrimPrefix(c.Metadata.Version, "v")
+				}
+				if err := mdOpts.apply(c.Metadata, src); err != nil {
+					fmt.Printf("Error updating chart metadata: %v\n", err)
+					exit(1)
+				}
+				if filled, err := mdOpts.enrich(c.Metadata, fileData(ch.Files, "doc.yaml")); err != nil {
+					fmt.Printf("Error: %v\n", err)
+					exit(1)
+				} else if len(filled) > 0 {
+					fmt.Printf("Filled %s of chart %s from doc.yaml\n", strings.Join(filled, ", "), name)
+				}
+			}
+			src := *ch.Metadata
+			updateMetadata(newChart, newChartName, &src)
+
+			if err := saveChart(newChart, output); err != nil {
+				fmt.Printf("Error saving extracted chart: %v\n", err)
+				exit(1)
+			}
+			reportWritten(filepath.Join(output, newChart.Name()))
+
+			// The remainder is the input chart without the extracted templates, so both charts
+			// together install the same resources as the input chart
+			remaining := make([]*chart.File, 0, len(ch.Templates))
+			for _, f := range ch.Templates {
+				if !extracted[prefix+f.Name] {
+					remaining = append(remaining, f)
+				}
+			}
+			ch.Templates = remaining
+			updateMetadata(ch, src.Name+"-without-"+ek.suffix, &src)
+			if err := saveChart(ch, output); err != nil {
+				fmt.Printf("Error saving remainder chart: %v\n", err)
+				exit(1)
+			}
+			reportWritten(filepath.Join(output, ch.Name()))
+			fmt.Printf("Extracted %s of %s into %s, the other templates into %s\n", ek.description, strings.TrimSuffix(prefix, "/"), newChart.Name(), ch.Name())
+		},
+	}
+
+	cmd.Flags().StringVar(&input, "input", "", "Path or URL of the input Helm chart directory or .tgz, .tar.zst or .tar file")
+	cmd.Flags().StringVar(&output, "output", "", "Output directory for the extracted and remainder charts")
+	cmd.Flags().StringVar(&kind, "kind", "webhook", "Kind of resources to extract (webhook)")
+	cmd.Flags().BoolVar(&semver, "semver", semver, "If true, use strict semver version (no v prefix)")
+	mdOpts.AddFlags(cmd.Flags())
+	inOpts.AddFlags(cmd.Flags())
+	valOpts.AddFlags(cmd.Flags())
+	_ = cobra.MarkFlagRequired(cmd.Flags(), "input")
+	_ = cmd.RegisterFlagCompletionFunc("input", completeChartInput)
+	_ = cobra.MarkFlagRequired(cmd.Flags(), "output")
+
+	return cmd
+}
+
+var (
+	validatingWebhookKind = schema.GroupKind{Group: "admissionregistration.k8s.io", Kind: "ValidatingWebhookConfiguration"}
+	mutatingWebhookKind   = schema.GroupKind{Group: "admissionregistration.k8s.io", Kind: "MutatingWebhookConfiguration"}
+	certificateKind       = schema.GroupKind{Group: "cert-manager.io", Kind: "Certificate"}
+	issuerKind            = schema.GroupKind{Group: "cert-manager.io", Kind: "Issuer"}
+	clusterIssuerKind     = schema.GroupKind{Group: "cert-manager.io", Kind: "ClusterIssuer"}
+	secretKind            = schema.GroupKind{Kind: "Secret"}
+)
+
+// selectWebhooks selects the admission webhook configurations and their certificate plumbing:
+// cert-manager Certificates serving the webhook services or injected as CA, their Issuers,
+// and the TLS Secrets holding the serving certificates. Those are the secretNames of the selected
+// Certificates, or named <service>-tls or <service>-cert when the chart generates the certificate.
+func selectWebhooks(manifests []renderedManifest) []renderedManifest {
+	var result []renderedManifest
+	services := map[string]bool{}
+	certs := map[string]bool{}
+	for _, m := range manifests {
+		gk := m.obj.GroupVersionKind().GroupKind()
+		if gk != validatingWebhookKind && gk != mutatingWebhookKind {
+			continue
+		}
+		result = append(result, m)
+		if ref, ok := m.obj.GetAnnotations()["cert-manager.io/inject-ca-from"]; ok {
+			certs[ref[strings.Index(ref, "/")+1:]] = true
+		}
+		webhooks, _, _ := unstructured.NestedSlice(m.obj.Object, "webhooks")
+		for _, w := range webhooks {
+			if w, ok := w.(map[string]any); ok {
+				if name, _, _ := unstructured.NestedString(w, "clientConfig", "service", "name"); name != "" {
+					services[name] = true
+				}
+			}
+		}
+	}
+	if len(result) == 0 {
+		return nil
+	}
+
+	issuers := map[string]bool{}
+	secrets := map[string]bool{}
+	for service := range services {
+		secrets[service+"-tls"] = true
+		secrets[service+"-cert"] = true
+	}
+	for _, m := range manifests {
+		if m.obj.GroupVersionKind().GroupKind() != certificateKind {
+			continue
+		}
+		dnsNames, _, _ := unstructured.NestedStringSlice(m.obj.Object, "spec", "dnsNames")
+		if !certs[m.obj.GetName()] && !slices.ContainsFunc(dnsNames, func(dns string) bool {
+			return services[strings.SplitN(dns, ".", 2)[0]]
+		}) {
+			continue
+		}
+		result = append(result, m)
+		if name, _, _ := unstructured.NestedString(m.obj.Object, "spec", "issuerRef", "name"); name != "" {
+			issuers[name] = true
+		}
+		if name, _, _ := unstructured.NestedString(m.obj.Object, "spec", "secretName"); name != "" {
+			secrets[name] = true
+		}
+	}
+
+	for _, m := range manifests {
+		gk := m.obj.GroupVersionKind().GroupKind()
+		switch {
+		case gk == issuerKind || gk == clusterIssuerKind:
+			if issuers[m.obj.GetName()] {
+				result = append(result, m)
+			}
+		case gk == secretKind:
+			tlsType, _, _ := unstructured.NestedString(m.obj.Object, "type")
+			if tlsType == "kubernetes.io/tls" && secrets[m.obj.GetName()] {
+				result = append(result, m)
+			}
+		}
+	}
+	return result
+}
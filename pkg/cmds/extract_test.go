@@ -0,0 +1,65 @@
+/*
+Copyright AppsCode Inc. and Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmds
+
+import (
+	"reflect"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/yaml"
+)
+
+func TestSelectWebhooks(t *testing.T) {
+	objects := []string{
+		`{apiVersion: admissionregistration.k8s.io/v1, kind: ValidatingWebhookConfiguration, metadata: {name: demo},
+		  webhooks: [{name: demo.example.com, clientConfig: {service: {name: demo-webhook, namespace: default}}}]}`,
+		`{apiVersion: cert-manager.io/v1, kind: Certificate, metadata: {name: demo-serving},
+		  spec: {dnsNames: [demo-webhook.default.svc], secretName: demo-serving-cert, issuerRef: {name: demo-issuer}}}`,
+		`{apiVersion: cert-manager.io/v1, kind: Certificate, metadata: {name: ingress},
+		  spec: {dnsNames: [demo.example.com], secretName: ingress-tls}}`,
+		`{apiVersion: cert-manager.io/v1, kind: Issuer, metadata: {name: demo-issuer}}`,
+		`{apiVersion: v1, kind: Secret, metadata: {name: demo-serving-cert}, type: kubernetes.io/tls}`,
+		`{apiVersion: v1, kind: Secret, metadata: {name: demo-webhook-tls}, type: kubernetes.io/tls}`,
+		`{apiVersion: v1, kind: Secret, metadata: {name: demo-webhook-client-tls}, type: kubernetes.io/tls}`,
+		`{apiVersion: v1, kind: Secret, metadata: {name: ingress-tls}, type: kubernetes.io/tls}`,
+		`{apiVersion: v1, kind: Service, metadata: {name: demo-webhook}}`,
+	}
+	var manifests []renderedManifest
+	for _, o := range objects {
+		obj := &unstructured.Unstructured{}
+		if err := yaml.Unmarshal([]byte(o), &obj.Object); err != nil {
+			t.Fatal(err)
+		}
+		manifests = append(manifests, renderedManifest{source: "demo/templates/webhook.yaml", obj: obj})
+	}
+
+	var got []string
+	for _, m := range selectWebhooks(manifests) {
+		got = append(got, m.obj.GetKind()+"/"+m.obj.GetName())
+	}
+	want := []string{
+		"ValidatingWebhookConfiguration/demo",
+		"Certificate/demo-serving",
+		"Issuer/demo-issuer",
+		"Secret/demo-serving-cert",
+		"Secret/demo-webhook-tls",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("selectWebhooks() = %v, want %v", got, want)
+	}
+}
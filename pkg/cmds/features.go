@@ -0,0 +1,339 @@
+/*
+Copyright AppsCode Inc. and Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmds
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/Masterminds/semver/v3"
+	"helm.sh/helm/v3/pkg/chart"
+	crdv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// crdFeature is a CRD feature along with the Kubernetes version it became generally available in.
+type crdFeature struct {
+	name       string
+	minVersion string
+	uses       func(crd *crdv1.CustomResourceDefinition) bool
+	// strip removes the feature from a CRD, nil if the CRD can not work without it
+	strip crdMutator
+}
+
+// crdFeatures is the capability table of CRD features, ordered by version.
+var crdFeatures = []crdFeature{
+	{
+		name:       "apiextensions.k8s.io/v1",
+		minVersion: "1.16",
+		uses: func(crd *crdv1.CustomResourceDefinition) bool {
+			return crd.APIVersion == crdv1.SchemeGroupVersion.String()
+		},
+	},
+	{
+		name:       "schema defaults",
+		minVersion: "1.17",
+		uses: func(crd *crdv1.CustomResourceDefinition) bool {
+			return anySchema(crd, func(s *crdv1.JSONSchemaProps) bool { return s.Default != nil })
+		},
+	},
+	{
+		name:       "CEL validation rules",
+		minVersion: "1.29",
+		uses: func(crd *crdv1.CustomResourceDefinition) bool {
+			return anySchema(crd, func(s *crdv1.JSONSchemaProps) bool { return len(s.XValidations) > 0 })
+		},
+		strip: stripSchemaExtensions([]string{"x-kubernetes-validations"}, map[schema.GroupKind][]string{}),
+	},
+	{
+		name:       "CEL validation rule reason and fieldPath",
+		minVersion: "1.30",
+		uses: func(crd *crdv1.CustomResourceDefinition) bool {
+			return anySchema(crd, func(s *crdv1.JSONSchemaProps) bool {
+				for _, rule := range s.XValidations {
+					if rule.Reason != nil || rule.FieldPath != "" {
+						return true
+					}
+				}
+				return false
+			})
+		},
+		strip: stripValidationRuleFields("reason", "fieldPath"),
+	},
+	{
+		name:       "selectableFields",
+		minVersion: "1.32",
+		uses: func(crd *crdv1.CustomResourceDefinition) bool {
+			for _, v := range crd.Spec.Versions {
+				if len(v.SelectableFields) > 0 {
+					return true
+				}
+			}
+			return false
+		},
+		strip: func(_ schema.GroupKind, obj map[string]any) (bool, error) {
+			versions, _, err := unstructured.NestedSlice(obj, "spec", "versions")
+			if err != nil {
+				return false, err
+			}
+			changed := false
+			for _, v := range versions {
+				if m := toMap(v); m != nil {
+					if _, ok := m["selectableFields"]; ok {
+						delete(m, "selectableFields")
+						changed = true
+					}
+				}
+			}
+			if !changed {
+				return false, nil
+			}
+			return true, unstructured.SetNestedSlice(obj, versions, "spec", "versions")
+		},
+	},
+}
+
+// anySchema reports whether fn holds for any schema node of any version of crd.
+func anySchema(crd *crdv1.CustomResourceDefinition, fn func(s *crdv1.JSONSchemaProps) bool) bool {
+	for _, v := range crd.Spec.Versions {
+		if v.Schema != nil && v.Schema.OpenAPIV3Schema != nil && anySchemaNode(v.Schema.OpenAPIV3Schema, fn) {
+			return true
+		}
+	}
+	return false
+}
+
+func anySchemaNode(s *crdv1.JSONSchemaProps, fn func(s *crdv1.JSONSchemaProps) bool) bool {
+	if fn(s) {
+		return true
+	}
+	var subs []crdv1.JSONSchemaProps
+	for _, m := range []map[string]crdv1.JSONSchemaProps{s.Properties, s.PatternProperties, s.Definitions} {
+		for _, key := range slices.Sorted(maps.Keys(m)) {
+			subs = append(subs, m[key])
+		}
+	}
+	subs = append(subs, s.AllOf...)
+	subs = append(subs, s.AnyOf...)
+	subs = append(subs, s.OneOf...)
+	if s.Not != nil {
+		subs = append(subs, *s.Not)
+	}
+	if s.Items != nil {
+		if s.Items.Schema != nil {
+			subs = append(subs, *s.Items.Schema)
+		}
+		subs = append(subs, s.Items.JSONSchemas...)
+	}
+	for _, b := range []*crdv1.JSONSchemaPropsOrBool{s.AdditionalProperties, s.AdditionalItems} {
+		if b != nil && b.Schema != nil {
+			subs = append(subs, *b.Schema)
+		}
+	}
+	for i := range subs {
+		if anySchemaNode(&subs[i], fn) {
+			return true
+		}
+	}
+	return false
+}
+
+// stripValidationRuleFields removes the given fields from every x-kubernetes-validations rule.
+func stripValidationRuleFields(fields ...string) crdMutator {
+	var strip func(s map[string]any) bool
+	strip = func(s map[string]any) bool {
+		changed := false
+		if rules, ok := s["x-kubernetes-validations"].([]any); ok {
+			for _, rule := range rules {
+				if m := toMap(rule); m != nil {
+					for _, f := range fields {
+						if _, ok := m[f]; ok {
+							delete(m, f)
+							changed = true
+						}
+					}
+				}
+			}
+		}
+		for key, v := range s {
+			switch key {
+			case "properties", "patternProperties", "definitions":
+				for _, sub := range toMap(v) {
+					if m := toMap(sub); m != nil && strip(m) {
+						changed = true
+					}
+				}
+			case "items", "additionalProperties", "additionalItems", "not", "allOf", "anyOf", "oneOf":
+				if m := toMap(v); m != nil {
+					changed = strip(m) || changed
+				} else if list, ok := v.([]any); ok {
+					for _, item := range list {
+						if m := toMap(item); m != nil && strip(m) {
+							changed = true
+						}
+					}
+				}
+			}
+		}
+		return changed
+	}
+	return func(_ schema.GroupKind, obj map[string]any) (bool, error) {
+		versions, _, err := unstructured.NestedSlice(obj, "spec", "versions")
+		if err != nil {
+			return false, err
+		}
+		changed := false
+		for _, v := range versions {
+			if s, ok, _ := unstructured.NestedMap(toMap(v), "schema", "openAPIV3Schema"); ok && strip(s) {
+				_ = unstructured.SetNestedMap(toMap(v), s, "schema", "openAPIV3Schema")
+				changed = true
+			}
+		}
+		if !changed {
+			return false, nil
+		}
+		return true, unstructured.SetNestedSlice(obj, versions, "spec", "versions")
+	}
+}
+
+// crdVariant is a copy of the CRDs targeting a range of Kubernetes versions.
+type crdVariant struct {
+	name        string
+	kubeVersion string
+	crdMap      map[schema.GroupKind]*chart.File
+}
+
+// kubeVersionVariants generates a variant of crdMap per Kubernetes version. Every variant targets the
+// versions from its own up to the next one, within kubeVersion, the constraint of the input chart, and
+// has the features not available in its version stripped. The lowest variant also covers all older versions.
+func kubeVersionVariants(name string, crdMap map[schema.GroupKind]*chart.File, kubeVersions []string, kubeVersion string) ([]crdVariant, error) {
+	versions := make([]*semver.Version, 0, len(kubeVersions))
+	for _, v := range kubeVersions {
+		sv, err := semver.NewVersion(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid Kubernetes version %q: %v", v, err)
+		}
+		versions = append(versions, sv)
+	}
+	sort.Sort(semver.Collection(versions))
+
+	crds := map[schema.GroupKind]*crdv1.CustomResourceDefinition{}
+	for _, key := range sortedCRDKeys(crdMap) {
+		crd, err := decodeCRD(crdMap[key].Data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse CRD %s/%s: %v", key.Kind, key.Group, err)
+		}
+		crds[key] = crd
+	}
+
+	var variants []crdVariant
+	for i, v := range versions {
+		minor := fmt.Sprintf("%d.%d", v.Major(), v.Minor())
+		variant := crdVariant{
+			name:   name + "-k8s-" + minor,
+			crdMap: maps.Clone(crdMap),
+		}
+		if i > 0 {
+			variant.kubeVersion = ">=" + minor + ".0-0"
+		}
+		if i < len(versions)-1 {
+			next := versions[i+1]
+			if variant.kubeVersion != "" {
+				variant.kubeVersion += " "
+			}
+			variant.kubeVersion += fmt.Sprintf("<%d.%d.0-0", next.Major(), next.Minor())
+		}
+		variant.kubeVersion = intersectKubeVersions(kubeVersion, variant.kubeVersion)
+
+		for _, f := range crdFeatures {
+			if !semver.MustParse(f.minVersion).GreaterThan(v) {
+				continue
+			}
+			if f.strip == nil {
+				for _, key := range sortedCRDKeys(crdMap) {
+					if f.uses(crds[key]) {
+						fmt.Printf("Warning: CRD %s/%s uses %s which requires Kubernetes %s, it may not work in variant %s\n", key.Kind, key.Group, f.name, f.minVersion, variant.name)
+					}
+				}
+				continue
+			}
+			modified, err := mutateCRDs(variant.crdMap, f.strip)
+			if err != nil {
+				return nil, err
+			}
+			for _, key := range modified {
+				fmt.Printf("Stripped %s from CRD %s/%s in variant %s\n", f.name, key.Kind, key.Group, variant.name)
+			}
+		}
+		variants = append(variants, variant)
+	}
+	return variants, nil
+}
+
+// intersectKubeVersions returns a semver constraint satisfied by the versions satisfying both a and b.
+// Constraints within an || alternative are and-ed, so every alternative of a is combined with every one of b.
+func intersectKubeVersions(a, b string) string {
+	a, b = strings.TrimSpace(a), strings.TrimSpace(b)
+	if a == "" || a == b {
+		return b
+	}
+	if b == "" {
+		return a
+	}
+	var result []string
+	for _, x := range strings.Split(a, "||") {
+		for _, y := range strings.Split(b, "||") {
+			result = append(result, strings.TrimSpace(x)+" "+strings.TrimSpace(y))
+		}
+	}
+	return strings.Join(result, " || ")
+}
+
+// minKubeVersion returns the lowest Kubernetes minor version supporting every feature used by the CRDs,
+// along with the CRD features requiring it. It returns an empty version if no feature is used.
+func minKubeVersion(crdMap map[schema.GroupKind]*chart.File) (string, []string, error) {
+	var result *semver.Version
+	var reasons []string
+	for _, key := range sortedCRDKeys(crdMap) {
+		crd, err := decodeCRD(crdMap[key].Data)
+		if err != nil {
+			return "", nil, fmt.Errorf("failed to parse CRD %s/%s: %v", key.Kind, key.Group, err)
+		}
+		for _, f := range crdFeatures {
+			if !f.uses(crd) {
+				continue
+			}
+			v := semver.MustParse(f.minVersion)
+			reason := fmt.Sprintf("%s/%s uses %s", key.Kind, key.Group, f.name)
+			switch {
+			case result == nil || v.GreaterThan(result):
+				result = v
+				reasons = []string{reason}
+			case v.Equal(result):
+				reasons = append(reasons, reason)
+			}
+		}
+	}
+	if result == nil {
+		return "", nil, nil
+	}
+	return fmt.Sprintf("%d.%d", result.Major(), result.Minor()), reasons, nil
+}
@@ -0,0 +1,64 @@
+/*
+Copyright AppsCode Inc. and Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmds
+
+import (
+	"testing"
+
+	"helm.sh/helm/v3/pkg/chart"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestIntersectKubeVersions(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want string
+	}{
+		{a: "", b: "<1.29.0-0", want: "<1.29.0-0"},
+		{a: ">=1.25.0-0", b: "", want: ">=1.25.0-0"},
+		{a: ">=1.25.0-0", b: ">=1.25.0-0", want: ">=1.25.0-0"},
+		{a: ">=1.25.0-0", b: ">=1.28.0-0 <1.29.0-0", want: ">=1.25.0-0 >=1.28.0-0 <1.29.0-0"},
+		{a: "~1.26.0 || ~1.28.0", b: "<1.28.0-0", want: "~1.26.0 <1.28.0-0 || ~1.28.0 <1.28.0-0"},
+	}
+	for _, tt := range tests {
+		if got := intersectKubeVersions(tt.a, tt.b); got != tt.want {
+			t.Errorf("intersectKubeVersions(%q, %q) = %q, want %q", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestKubeVersionVariantsKeepInputConstraint(t *testing.T) {
+	crdMap := map[schema.GroupKind]*chart.File{
+		{Group: "example.com", Kind: "Foo"}: {Name: "crds/foo.yaml", Data: []byte(v1CRD)},
+	}
+	variants, err := kubeVersionVariants("demo-crds", crdMap, []string{"1.29", "1.27"}, ">=1.26.0-0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		"demo-crds-k8s-1.27": ">=1.26.0-0 <1.29.0-0",
+		"demo-crds-k8s-1.29": ">=1.26.0-0 >=1.29.0-0",
+	}
+	if len(variants) != len(want) {
+		t.Fatalf("got %d variants, want %d", len(variants), len(want))
+	}
+	for _, v := range variants {
+		if v.kubeVersion != want[v.name] {
+			t.Errorf("kubeVersion of %s = %q, want %q", v.name, v.kubeVersion, want[v.name])
+		}
+	}
+}
@@ -0,0 +1,138 @@
+/*
+Copyright AppsCode Inc. and Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmds
+
+import (
+	"bytes"
+	"os"
+	"path"
+	"time"
+
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/ignore"
+)
+
+// defaultHelmignore mirrors the .helmignore generated by `helm create`.
+// It is applied in addition to the .helmignore of the input chart.
+const defaultHelmignore = `.DS_Store
+.git/
+.gitignore
+.bzr/
+.bzrignore
+.hg/
+.hgignore
+.svn/
+*.swp
+*.bak
+*.tmp
+*.orig
+*~
+.project
+.idea/
+*.tmproj
+.vscode/
+`
+
+// ignoreRules returns the .helmignore rules of ch combined with the built-in defaults.
+func ignoreRules(ch *chart.Chart) (*ignore.Rules, error) {
+	var buf bytes.Buffer
+	for _, f := range ch.Raw {
+		if f.Name == ignore.HelmIgnore {
+			buf.Write(f.Data)
+			buf.WriteByte('\n')
+		}
+	}
+	buf.WriteString(defaultHelmignore)
+
+	rules, err := ignore.Parse(&buf)
+	if err != nil {
+		return nil, err
+	}
+	rules.AddDefaults()
+	return rules, nil
+}
+
+// isIgnored reports whether the file name, or any of its parent directories, is ignored by rules.
+func isIgnored(rules *ignore.Rules, name string) bool {
+	for dir := path.Dir(name); dir != "." && dir != "/"; dir = path.Dir(dir) {
+		if rules.Ignore(dir, fileInfo{name: path.Base(dir), dir: true}) {
+			return true
+		}
+	}
+	return rules.Ignore(name, fileInfo{name: path.Base(name)})
+}
+
+// filterIgnored splits files into the ones to keep and the names of the ignored ones.
+func filterIgnored(rules *ignore.Rules, files []*chart.File) ([]*chart.File, []string) {
+	kept := make([]*chart.File, 0, len(files))
+	var ignored []string
+	for _, f := range files {
+		if isIgnored(rules, f.Name) {
+			ignored = append(ignored, f.Name)
+			continue
+		}
+		kept = append(kept, f)
+	}
+	return kept, ignored
+}
+
+// removeIgnoredFiles drops the templates and files matched by .helmignore from ch and,
+// using their own .helmignore, from its subcharts. The full paths of the dropped files are returned.
+func removeIgnoredFiles(ch *chart.Chart) ([]string, error) {
+	rules, err := ignoreRules(ch)
+	if err != nil {
+		return nil, err
+	}
+
+	var result []string
+	var ignored []string
+	ch.Templates, ignored = filterIgnored(rules, ch.Templates)
+	result = append(result, ignored...)
+	ch.Files, ignored = filterIgnored(rules, ch.Files)
+	result = append(result, ignored...)
+	for i := range result {
+		result[i] = path.Join(ch.ChartFullPath(), result[i])
+	}
+
+	for _, dep := range ch.Dependencies() {
+		ignored, err := removeIgnoredFiles(dep)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, ignored...)
+	}
+	return result, nil
+}
+
+// fileInfo is a minimal os.FileInfo used to evaluate .helmignore rules against in-memory chart files.
+type fileInfo struct {
+	name string
+	dir  bool
+}
+
+func (fi fileInfo) Name() string       { return fi.name }
+func (fi fileInfo) Size() int64        { return 0 }
+func (fi fileInfo) ModTime() time.Time { return time.Time{} }
+func (fi fileInfo) IsDir() bool        { return fi.dir }
+func (fi fileInfo) Sys() any           { return nil }
+
+func (fi fileInfo) Mode() os.FileMode {
+	if fi.dir {
+		return os.ModeDir | 0o755
+	}
+	return 0o644
+}
@@ -0,0 +1,155 @@
+/*
+Copyright AppsCode Inc. and Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmds
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/Masterminds/semver/v3"
+	"sigs.k8s.io/yaml"
+)
+
+// helmRepo is a chart repository added with `helm repo add`.
+type helmRepo struct {
+	Name string `json:"name"`
+	URL  string `json:"url"`
+}
+
+// helmRepos reads the repositories configured for the helm cli. Like helm, it honors
+// HELM_REPOSITORY_CONFIG and HELM_CONFIG_HOME, falling back to the user config directory.
+func helmRepos() ([]helmRepo, error) {
+	filename := os.Getenv("HELM_REPOSITORY_CONFIG")
+	if filename == "" {
+		filename = filepath.Join(helmHome("HELM_CONFIG_HOME", os.UserConfigDir), "repositories.yaml")
+	}
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	var file struct {
+		Repositories []helmRepo `json:"repositories"`
+	}
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %v", filename, err)
+	}
+	return file.Repositories, nil
+}
+
+// helmRepoIndex reads the index of repo cached by `helm repo update`.
+func helmRepoIndex(repo string) (*repoIndex, error) {
+	dir := os.Getenv("HELM_REPOSITORY_CACHE")
+	if dir == "" {
+		dir = filepath.Join(helmHome("HELM_CACHE_HOME", os.UserCacheDir), "repository")
+	}
+	filename := filepath.Join(dir, repo+"-index.yaml")
+	if _, err := os.Stat(filename); err != nil {
+		return nil, fmt.Errorf("no cached index for repository %s, run helm repo update: %v", repo, err)
+	}
+	index, err := loadRepoIndex(filename)
+	if err != nil {
+		return nil, err
+	}
+	index.sortEntries()
+	return index, nil
+}
+
+func helmHome(env string, userDir func() (string, error)) string {
+	if dir := os.Getenv(env); dir != "" {
+		return dir
+	}
+	dir, err := userDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "helm")
+}
+
+// splitRepoChartRef splits <repo>/<chart>[@<version>] if repo is a configured helm repository.
+func splitRepoChartRef(input string) (repo helmRepo, name, version string, ok bool) {
+	repoName, rest, found := strings.Cut(input, "/")
+	if !found || rest == "" || strings.Contains(rest, "/") {
+		return helmRepo{}, "", "", false
+	}
+	repos, err := helmRepos()
+	if err != nil {
+		return helmRepo{}, "", "", false
+	}
+	for _, r := range repos {
+		if r.Name == repoName {
+			name, version, _ = strings.Cut(rest, "@")
+			return r, name, version, true
+		}
+	}
+	return helmRepo{}, "", "", false
+}
+
+// resolveRepoChart returns the archive URL of <repo>/<chart>[@<version>] from the cached index of
+// a configured helm repository. version may be a semver constraint; it defaults to the latest
+// stable version, like `helm pull`.
+func resolveRepoChart(repo helmRepo, name, version string) (string, error) {
+	index, err := helmRepoIndex(repo.Name)
+	if err != nil {
+		return "", err
+	}
+	versions, ok := index.Entries[name]
+	if !ok {
+		return "", fmt.Errorf("chart %s not found in repository %s", name, repo.Name)
+	}
+
+	var constraint *semver.Constraints
+	if version != "" {
+		if constraint, err = semver.NewConstraint(version); err != nil {
+			return "", fmt.Errorf("invalid version %q: %v", version, err)
+		}
+	}
+	for _, cv := range versions {
+		if version == cv.Version {
+			return repoChartURL(repo, cv)
+		}
+		v, err := semver.NewVersion(cv.Version)
+		if err != nil {
+			continue
+		}
+		if constraint == nil && v.Prerelease() == "" || constraint != nil && constraint.Check(v) {
+			return repoChartURL(repo, cv)
+		}
+	}
+	if version == "" {
+		version = "latest"
+	}
+	return "", fmt.Errorf("chart %s version %s not found in repository %s", name, version, repo.Name)
+}
+
+// repoChartURL resolves the first URL of cv, which may be relative to the repository URL.
+func repoChartURL(repo helmRepo, cv *chartVersion) (string, error) {
+	if len(cv.URLs) == 0 {
+		return "", fmt.Errorf("chart %s version %s has no URL", cv.Name, cv.Version)
+	}
+	base, err := url.Parse(strings.TrimSuffix(repo.URL, "/") + "/")
+	if err != nil {
+		return "", err
+	}
+	ref, err := url.Parse(cv.URLs[0])
+	if err != nil {
+		return "", err
+	}
+	return base.ResolveReference(ref).String(), nil
+}
@@ -0,0 +1,75 @@
+/*
+Copyright AppsCode Inc. and Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmds
+
+import (
+	"regexp"
+	"strings"
+
+	"helm.sh/helm/v3/pkg/chart"
+)
+
+var (
+	defineRe    = regexp.MustCompile(`{{-?\s*define\s+"([^"]+)"`)
+	templateRef = regexp.MustCompile(`\b(?:include|template)\s+"([^"]+)"`)
+)
+
+func isHelperTemplate(name string) bool {
+	return strings.HasPrefix(name, "templates/_")
+}
+
+// referencedHelpers returns the names of the helper files defining templates used by the
+// templates in files, directly or through other helpers. Helpers are matched by the literal
+// names passed to include and template; names computed at render time are not detected.
+func referencedHelpers(templates, files []*chart.File) map[string]bool {
+	defined := map[string]*chart.File{}
+	for _, f := range templates {
+		if !isHelperTemplate(f.Name) {
+			continue
+		}
+		for _, m := range defineRe.FindAllSubmatch(f.Data, -1) {
+			defined[string(m[1])] = f
+		}
+	}
+
+	var queue []string
+	for _, f := range files {
+		if strings.HasPrefix(f.Name, "templates/") && !isHelperTemplate(f.Name) {
+			queue = append(queue, templateRefs(f.Data)...)
+		}
+	}
+	result := map[string]bool{}
+	for len(queue) > 0 {
+		name := queue[0]
+		queue = queue[1:]
+		f, ok := defined[name]
+		if !ok || result[f.Name] {
+			continue
+		}
+		result[f.Name] = true
+		queue = append(queue, templateRefs(f.Data)...)
+	}
+	return result
+}
+
+func templateRefs(data []byte) []string {
+	var refs []string
+	for _, m := range templateRef.FindAllSubmatch(data, -1) {
+		refs = append(refs, string(m[1]))
+	}
+	return refs
+}
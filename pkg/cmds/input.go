@@ -0,0 +1,334 @@
+/*
+Copyright AppsCode Inc. and Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmds
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/spf13/pflag"
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/chart/loader"
+	"helm.sh/helm/v3/pkg/provenance"
+)
+
+const gitInputPrefix = "git+"
+
+// inputOptions controls how the input chart is fetched and verified.
+type inputOptions struct {
+	sha256    string
+	verify    bool
+	keyring   string
+	plainHTTP bool
+
+	verifySignature    bool
+	cosignKey          string
+	certIdentity       string
+	certIdentityRegexp string
+	certOIDCIssuer     string
+
+	// out receives the progress and warning messages, os.Stdout if nil
+	out io.Writer
+}
+
+func (o *inputOptions) logf(format string, a ...any) {
+	out := o.out
+	if out == nil {
+		out = os.Stdout
+	}
+	_, _ = fmt.Fprintf(out, format, a...)
+}
+
+func newInputOptions() inputOptions {
+	return inputOptions{
+		keyring: defaultKeyring(),
+	}
+}
+
+func (o *inputOptions) AddFlags(fs *pflag.FlagSet) {
+	fs.StringVar(&o.sha256, "sha256", o.sha256, "Expected SHA256 digest of the input chart archive, required to match before the chart is loaded")
+	fs.BoolVar(&o.verify, "verify", o.verify, "If true, verify the input chart archive against its .prov file before repackaging")
+	fs.StringVar(&o.keyring, "keyring", o.keyring, "Keyring containing the public keys used to verify the input chart provenance")
+	fs.BoolVar(&o.plainHTTP, "plain-http", o.plainHTTP, "If true, use insecure HTTP connections to pull oci:// input charts")
+	fs.BoolVar(&o.verifySignature, "verify-signature", o.verifySignature, "If true, verify the cosign signature of oci:// input charts before pulling them")
+	fs.StringVar(&o.cosignKey, "cosign-key", o.cosignKey, "Public key (path, URL or KMS reference) used to verify the cosign signature of the input chart")
+	fs.StringVar(&o.certIdentity, "certificate-identity", o.certIdentity, "Identity expected in the keyless signing certificate of the input chart")
+	fs.StringVar(&o.certIdentityRegexp, "certificate-identity-regexp", o.certIdentityRegexp, "Regular expression matching the identity in the keyless signing certificate of the input chart")
+	fs.StringVar(&o.certOIDCIssuer, "certificate-oidc-issuer", o.certOIDCIssuer, "OIDC issuer expected in the keyless signing certificate of the input chart")
+}
+
+// load loads the chart referenced by input. Besides a chart directory or archive (gzip, zstd or
+// uncompressed tar), input may be an http(s) URL of a chart archive, <repo>/<chart>[@<version>]
+// of a configured helm repository, an OCI chart reference oci://registry/repo/chart:version or a git repository in the form git+https://github.com/org/repo//path/to/chart?ref=v1.0.0
+//
+// If strict is true, charts without provenance are rejected when verification is requested.
+func (o *inputOptions) load(input string, strict bool) (*chart.Chart, error) {
+	defer startSpan("load", "input", input).End()
+
+	if o.verifySignature && !strings.HasPrefix(input, ociInputPrefix) {
+		return nil, fmt.Errorf("--verify-signature is only supported for oci:// inputs")
+	}
+	if strings.HasPrefix(input, gitInputPrefix) {
+		if o.sha256 != "" {
+			return nil, fmt.Errorf("--sha256 is not supported for git inputs")
+		}
+		if err := o.missingProvenance(input, strict); err != nil {
+			return nil, err
+		}
+		return loadChartFromGit(input)
+	}
+
+	// <repo>/<chart>[@<version>] of a repository added with `helm repo add`
+	if _, err := os.Stat(input); err != nil && !isURL(input) && !strings.HasPrefix(input, ociInputPrefix) {
+		if repo, name, version, ok := splitRepoChartRef(input); ok {
+			u, err := resolveRepoChart(repo, name, version)
+			if err != nil {
+				return nil, err
+			}
+			o.logf("Resolved %s to %s\n", input, u)
+			input = u
+		}
+	}
+
+	var data, prov []byte
+	if strings.HasPrefix(input, ociInputPrefix) {
+		var err error
+		if data, err = o.pullOCIChart(input); err != nil {
+			return nil, err
+		}
+	} else if isURL(input) {
+		var err error
+		if data, err = downloadFile(input); err != nil {
+			return nil, err
+		}
+		if data == nil {
+			return nil, fmt.Errorf("failed to download %s: not found", input)
+		}
+		if o.verify {
+			if prov, err = downloadFile(input + ".prov"); err != nil {
+				return nil, err
+			}
+		}
+		if o.sha256 == "" {
+			o.logf("Warning: no --sha256 provided, the digest of %s is not verified\n", input)
+		}
+	} else if fi, err := os.Stat(input); err != nil {
+		return nil, err
+	} else if fi.IsDir() {
+		if o.sha256 != "" {
+			return nil, fmt.Errorf("--sha256 is not supported for chart directories")
+		}
+		if err := o.missingProvenance(input, strict); err != nil {
+			return nil, err
+		}
+		dir, err := chartDir(input)
+		if err != nil {
+			return nil, err
+		}
+		return loader.LoadDir(dir)
+	} else {
+		if data, err = os.ReadFile(input); err != nil {
+			return nil, err
+		}
+		if o.verify {
+			if prov, err = os.ReadFile(input + ".prov"); err != nil && !errors.Is(err, fs.ErrNotExist) {
+				return nil, err
+			}
+		}
+	}
+
+	progressf("Read %s of chart archive %s", formatBytes(int64(len(data))), input)
+	if o.sha256 != "" {
+		if err := verifySHA256(data, o.sha256); err != nil {
+			return nil, err
+		}
+	}
+	if o.verify {
+		if prov == nil {
+			if err := o.missingProvenance(input, strict); err != nil {
+				return nil, err
+			}
+		} else if err := o.verifyProvenance(input, data, prov); err != nil {
+			return nil, err
+		}
+	}
+	return loadArchiveData(data)
+}
+
+// missingProvenance handles an input that has no provenance file while verification is requested.
+func (o *inputOptions) missingProvenance(input string, strict bool) error {
+	if !o.verify {
+		return nil
+	}
+	if strict {
+		return fmt.Errorf("no provenance file found for %s", input)
+	}
+	o.logf("Warning: no provenance file found for %s, skipping verification\n", input)
+	return nil
+}
+
+// verifyProvenance checks the signature of the provenance file with the keyring
+// and the digest of the archive it records.
+func (o *inputOptions) verifyProvenance(input string, archive, prov []byte) error {
+	dir, err := os.MkdirTemp("", "chart-packer-verify-")
+	if err != nil {
+		return err
+	}
+	defer func() { _ = os.RemoveAll(dir) }()
+
+	// the provenance file records the digest by archive file name
+	name := path.Base(input)
+	if isURL(input) {
+		if u, err := url.Parse(input); err == nil {
+			name = path.Base(u.Path)
+		}
+	}
+	archivePath := filepath.Join(dir, name)
+	if err := os.WriteFile(archivePath, archive, 0o644); err != nil {
+		return err
+	}
+	if err := os.WriteFile(archivePath+".prov", prov, 0o644); err != nil {
+		return err
+	}
+
+	sig, err := provenance.NewFromKeyring(o.keyring, "")
+	if err != nil {
+		return fmt.Errorf("failed to load keyring %s: %v", o.keyring, err)
+	}
+	ver, err := sig.Verify(archivePath, archivePath+".prov")
+	if err != nil {
+		return fmt.Errorf("failed to verify provenance of %s: %v", input, err)
+	}
+	for name := range ver.SignedBy.Identities {
+		o.logf("Verified provenance of %s signed by %s (%s)\n", input, name, ver.FileHash)
+	}
+	return nil
+}
+
+// defaultKeyring returns the public keyring used by GnuPG, like `helm verify` does.
+func defaultKeyring() string {
+	if home := os.Getenv("GNUPGHOME"); home != "" {
+		return filepath.Join(home, "pubring.gpg")
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".gnupg", "pubring.gpg")
+}
+
+// loadChart loads the chart referenced by input without any verification.
+func loadChart(input string) (*chart.Chart, error) {
+	return (&inputOptions{}).load(input, false)
+}
+
+func isURL(input string) bool {
+	return strings.HasPrefix(input, "http://") || strings.HasPrefix(input, "https://")
+}
+
+// downloadFile fetches the content of an http(s) URL. It returns nil if the URL is not found.
+func downloadFile(u string) ([]byte, error) {
+	client := &http.Client{Timeout: 5 * time.Minute}
+	resp, err := client.Get(u)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to download %s: %s", u, resp.Status)
+	}
+	return io.ReadAll(resp.Body)
+}
+
+// verifySHA256 checks data against a hex encoded digest, optionally prefixed with "sha256:".
+func verifySHA256(data []byte, expected string) error {
+	expected = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(expected), "sha256:"))
+	sum := sha256.Sum256(data)
+	if actual := hex.EncodeToString(sum[:]); actual != expected {
+		return fmt.Errorf("sha256 mismatch: expected %s, got %s", expected, actual)
+	}
+	return nil
+}
+
+// loadChartFromGit clones the repository at the requested ref and loads the chart subdirectory.
+func loadChartFromGit(input string) (*chart.Chart, error) {
+	repo, subdir, ref, err := parseGitInput(input)
+	if err != nil {
+		return nil, err
+	}
+
+	dir, err := os.MkdirTemp("", "chart-packer-git-")
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = os.RemoveAll(dir) }()
+
+	// fetching a single ref works for branches, tags and commit SHAs alike
+	if ref == "" {
+		ref = "HEAD"
+	}
+	for _, args := range [][]string{
+		{"init", "--quiet"},
+		{"remote", "add", "origin", repo},
+		{"fetch", "--quiet", "--depth", "1", "origin", ref},
+		{"checkout", "--quiet", "FETCH_HEAD"},
+	} {
+		if err := runGit(dir, args...); err != nil {
+			return nil, err
+		}
+	}
+
+	chartDir := filepath.Join(dir, filepath.FromSlash(subdir))
+	if rel, err := filepath.Rel(dir, chartDir); err != nil || strings.HasPrefix(rel, "..") {
+		return nil, fmt.Errorf("chart path %q is outside of the repository", subdir)
+	}
+	return loader.Load(chartDir)
+}
+
+// parseGitInput splits git+https://host/org/repo//path?ref=x into the repository URL, the path
+// of the chart inside the repository and the ref to check out.
+func parseGitInput(input string) (repo, subdir, ref string, err error) {
+	u, err := url.Parse(strings.TrimPrefix(input, gitInputPrefix))
+	if err != nil {
+		return "", "", "", fmt.Errorf("invalid git input %q: %v", input, err)
+	}
+	if u.Scheme == "" || (u.Host == "" && u.Scheme != "file") {
+		return "", "", "", fmt.Errorf("invalid git input %q: expected git+<scheme>://<host>/<repo>[//<path>][?ref=<ref>]", input)
+	}
+	ref = u.Query().Get("ref")
+	u.RawQuery = ""
+
+	if idx := strings.Index(u.Path, "//"); idx >= 0 {
+		subdir = strings.Trim(u.Path[idx+2:], "/")
+		u.Path = u.Path[:idx]
+	}
+	return u.String(), subdir, ref, nil
+}
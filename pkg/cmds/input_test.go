@@ -0,0 +1,44 @@
+/*
+Copyright AppsCode Inc. and Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmds
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/chartutil"
+)
+
+func TestInputOptionsLoadWritesMessagesToOut(t *testing.T) {
+	dir := t.TempDir()
+	ch := &chart.Chart{Metadata: &chart.Metadata{APIVersion: chart.APIVersionV2, Name: "demo", Version: "0.1.0"}}
+	if err := chartutil.SaveDir(ch, dir); err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	o := &inputOptions{verify: true, out: &out}
+	if _, err := o.load(filepath.Join(dir, "demo"), false); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), "Warning: no provenance file found") {
+		t.Errorf("expected the provenance warning in out, got %q", out.String())
+	}
+}
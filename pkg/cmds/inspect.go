@@ -0,0 +1,276 @@
+/*
+Copyright AppsCode Inc. and Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmds
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+	"helm.sh/helm/v3/pkg/chart"
+	crdv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/yaml"
+)
+
+func NewCmdInspect() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:               "inspect",
+		Short:             "Inspect the content of a chart",
+		DisableAutoGenTag: true,
+	}
+	cmd.AddCommand(NewCmdInspectAPIs())
+	cmd.AddCommand(NewCmdInspectCRDs())
+	return cmd
+}
+
+// apiUsage is an apiVersion and kind rendered by a chart.
+type apiUsage struct {
+	APIVersion string   `json:"apiVersion"`
+	Kind       string   `json:"kind"`
+	Scope      string   `json:"scope"`
+	Count      int      `json:"count"`
+	Sources    []string `json:"sources"`
+}
+
+// apiReport lists the APIs a chart uses and the cluster capabilities it needs.
+type apiReport struct {
+	Chart        string     `json:"chart"`
+	Version      string     `json:"version"`
+	KubeVersion  string     `json:"kubeVersion,omitempty"`
+	APIs         []apiUsage `json:"apis"`
+	Groups       []string   `json:"groups"`
+	Capabilities []string   `json:"capabilities"`
+}
+
+func NewCmdInspectAPIs() *cobra.Command {
+	var (
+		input   string
+		format  = "table"
+		inOpts  = newInputOptions()
+		valOpts valuesOptions
+	)
+	cmd := &cobra.Command{
+		Use:                   "apis",
+		Short:                 "Report the APIs used and cluster capabilities required by a chart",
+		DisableFlagsInUseLine: true,
+		DisableAutoGenTag:     true,
+		Run: func(cmd *cobra.Command, args []string) {
+			if format != "table" && format != "json" {
+				fmt.Printf("Error: unsupported format %q, use table or json\n", format)
+				exit(1)
+			}
+			vals, err := valOpts.merge()
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
+				exit(1)
+			}
+			// keep stdout for the report, which may be parsed with --format json
+			inOpts.out = os.Stderr
+			ch, err := inOpts.load(input, false)
+			if err != nil {
+				fmt.Printf("Error loading chart: %v\n", err)
+				exit(1)
+			}
+			renderCRDTemplatesQuietly(ch, vals)
+			manifests, err := renderManifests(ch, vals, defaultReleaseOptions(ch))
+			if err != nil {
+				fmt.Printf("Error rendering chart: %v\n", err)
+				exit(1)
+			}
+
+			report := buildAPIReport(ch, manifests)
+			if format == "json" {
+				data, err := json.MarshalIndent(report, "", "  ")
+				if err != nil {
+					fmt.Printf("Error: %v\n", err)
+					exit(1)
+				}
+				fmt.Println(string(data))
+				return
+			}
+			printAPIReport(os.Stdout, report)
+		},
+	}
+
+	cmd.Flags().StringVar(&input, "input", "", "Path or URL of the input Helm chart directory or .tgz, .tar.zst or .tar file")
+	cmd.Flags().StringVar(&format, "format", format, "Output format, table or json")
+	inOpts.AddFlags(cmd.Flags())
+	valOpts.AddFlags(cmd.Flags())
+	_ = cobra.MarkFlagRequired(cmd.Flags(), "input")
+	_ = cmd.RegisterFlagCompletionFunc("input", completeChartInput)
+
+	return cmd
+}
+
+// renderCRDTemplatesQuietly renders templated CRD files in place, reporting failures on stderr
+// to keep the report on stdout parsable.
+func renderCRDTemplatesQuietly(ch *chart.Chart, vals map[string]any) {
+	crds := templatedCRDs(ch)
+	if len(crds) == 0 {
+		return
+	}
+	rendered, err := renderCRDFiles(ch, vals)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to render CRD templates: %v\n", err)
+	}
+	for _, crd := range crds {
+		if out, ok := rendered[crd.Filename]; ok {
+			crd.File.Data = []byte(out)
+		}
+	}
+}
+
+// buildAPIReport collects the APIs used by the CRDs and rendered templates of ch.
+func buildAPIReport(ch *chart.Chart, manifests []renderedManifest) apiReport {
+	crdScopes := map[schema.GroupKind]crdv1.ResourceScope{}
+	usages := map[schema.GroupVersionKind]*apiUsage{}
+	use := func(gvk schema.GroupVersionKind, source string) {
+		u, ok := usages[gvk]
+		if !ok {
+			apiVersion, kind := gvk.ToAPIVersionAndKind()
+			u = &apiUsage{APIVersion: apiVersion, Kind: kind}
+			usages[gvk] = u
+		}
+		u.Count++
+		if !slices.Contains(u.Sources, source) {
+			u.Sources = append(u.Sources, source)
+		}
+	}
+
+	for _, crd := range ch.CRDObjects() {
+		items, err := unwrapCRDList(crd.File)
+		if err != nil {
+			continue
+		}
+		for _, item := range items {
+			var obj struct {
+				APIVersion string `json:"apiVersion"`
+				Kind       string `json:"kind"`
+			}
+			if err := yaml.Unmarshal(item.Data, &obj); err != nil || obj.Kind == "" {
+				continue
+			}
+			use(schema.FromAPIVersionAndKind(obj.APIVersion, obj.Kind), crd.Filename)
+		}
+		addCRDScope(crdScopes, crd.File.Data)
+	}
+	for _, m := range manifests {
+		use(m.obj.GroupVersionKind(), m.source)
+		if m.obj.GroupVersionKind().GroupKind() == crdv1.Kind("CustomResourceDefinition") {
+			if data, err := yaml.Marshal(m.obj.Object); err == nil {
+				addCRDScope(crdScopes, data)
+			}
+		}
+	}
+
+	report := apiReport{
+		Chart:       ch.Name(),
+		Version:     ch.Metadata.Version,
+		KubeVersion: ch.Metadata.KubeVersion,
+	}
+	groups := map[string]bool{}
+	var clusterScoped, external []string
+	for _, gvk := range slices.SortedFunc(maps.Keys(usages), compareGVK) {
+		u := usages[gvk]
+		scope, known := resourceScope(gvk.GroupKind(), crdScopes)
+		u.Scope = string(scope)
+		if !known {
+			u.Scope = "Unknown"
+			external = append(external, gvk.Kind+"/"+gvk.Group)
+		} else if _, ok := crdScopes[gvk.GroupKind()]; !ok && !isBuiltinGroup(gvk.Group) {
+			external = append(external, gvk.Kind+"/"+gvk.Group)
+		}
+		if scope == crdv1.ClusterScoped {
+			clusterScoped = append(clusterScoped, gvk.Kind)
+		}
+		sort.Strings(u.Sources)
+		report.APIs = append(report.APIs, *u)
+
+		group := gvk.Group
+		if group == "" {
+			group = "core"
+		}
+		groups[group] = true
+	}
+	report.Groups = slices.Sorted(maps.Keys(groups))
+
+	if len(clusterScoped) > 0 {
+		report.Capabilities = append(report.Capabilities, "cluster-admin privileges to create cluster scoped resources: "+strings.Join(slices.Compact(slices.Sorted(slices.Values(clusterScoped))), ", "))
+	}
+	if len(ch.CRDObjects()) > 0 || usages[crdv1.SchemeGroupVersion.WithKind("CustomResourceDefinition")] != nil {
+		report.Capabilities = append(report.Capabilities, "permission to install CustomResourceDefinitions")
+	}
+	if len(external) > 0 {
+		report.Capabilities = append(report.Capabilities, "CRDs installed separately for: "+strings.Join(slices.Compact(slices.Sorted(slices.Values(external))), ", "))
+	}
+	if groups["admissionregistration.k8s.io"] {
+		report.Capabilities = append(report.Capabilities, "admission webhooks or policies")
+	}
+	if hasHooks(manifests) {
+		report.Capabilities = append(report.Capabilities, "Helm hooks (pre/post install, upgrade or delete)")
+	}
+	if ch.Metadata.KubeVersion != "" {
+		report.Capabilities = append(report.Capabilities, "Kubernetes version "+ch.Metadata.KubeVersion)
+	}
+	return report
+}
+
+func compareGVK(a, b schema.GroupVersionKind) int {
+	return strings.Compare(a.Group+"/"+a.Version+"/"+a.Kind, b.Group+"/"+b.Version+"/"+b.Kind)
+}
+
+// isBuiltinGroup reports whether group is served by Kubernetes itself: the core group,
+// unqualified names like apps, or *.k8s.io.
+func isBuiltinGroup(group string) bool {
+	return group == "" || !strings.Contains(group, ".") || strings.HasSuffix(group, ".k8s.io")
+}
+
+func hasHooks(manifests []renderedManifest) bool {
+	for _, m := range manifests {
+		if _, ok := m.obj.GetAnnotations()["helm.sh/hook"]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+func printAPIReport(out io.Writer, report apiReport) {
+	fmt.Fprintf(out, "Chart: %s %s\n\n", report.Chart, report.Version)
+
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "APIVERSION\tKIND\tSCOPE\tCOUNT")
+	for _, u := range report.APIs {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%d\n", u.APIVersion, u.Kind, u.Scope, u.Count)
+	}
+	_ = w.Flush()
+
+	fmt.Fprintf(out, "\nAPI groups: %s\n", strings.Join(report.Groups, ", "))
+	if len(report.Capabilities) > 0 {
+		fmt.Fprintln(out, "\nRequired capabilities:")
+		for _, c := range report.Capabilities {
+			fmt.Fprintf(out, "  - %s\n", c)
+		}
+	}
+}
@@ -0,0 +1,171 @@
+/*
+Copyright AppsCode Inc. and Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmds
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+	"helm.sh/helm/v3/pkg/chart"
+	crdv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/yaml"
+)
+
+// inspectedCRD is a CRD found in a chart.
+type inspectedCRD struct {
+	crdInventoryEntry `json:",inline"`
+	APIVersion        string `json:"apiVersion"`
+	Size              int    `json:"size"`
+	Source            string `json:"source"`
+	File              string `json:"file"`
+}
+
+func NewCmdInspectCRDs() *cobra.Command {
+	var (
+		input   string
+		format  = "table"
+		inOpts  = newInputOptions()
+		valOpts valuesOptions
+	)
+	cmd := &cobra.Command{
+		Use:                   "crds",
+		Short:                 "List the CRDs of a chart and its subcharts",
+		DisableFlagsInUseLine: true,
+		DisableAutoGenTag:     true,
+		Run: func(cmd *cobra.Command, args []string) {
+			if format != "table" && format != "json" {
+				fmt.Printf("Error: unsupported format %q, use table or json\n", format)
+				exit(1)
+			}
+			vals, err := valOpts.merge()
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
+				exit(1)
+			}
+			// keep stdout for the report, which may be parsed with --format json
+			inOpts.out = os.Stderr
+			ch, err := inOpts.load(input, false)
+			if err != nil {
+				fmt.Printf("Error loading chart: %v\n", err)
+				exit(1)
+			}
+			renderCRDTemplatesQuietly(ch, vals)
+
+			crds, err := inspectCRDs(ch, vals)
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
+				exit(1)
+			}
+			if format == "json" {
+				data, err := json.MarshalIndent(crds, "", "  ")
+				if err != nil {
+					fmt.Printf("Error: %v\n", err)
+					exit(1)
+				}
+				fmt.Println(string(data))
+				return
+			}
+			printCRDs(os.Stdout, crds)
+		},
+	}
+
+	cmd.Flags().StringVar(&input, "input", "", "Path or URL of the input Helm chart directory or .tgz, .tar.zst or .tar file")
+	cmd.Flags().StringVar(&format, "format", format, "Output format, table or json")
+	inOpts.AddFlags(cmd.Flags())
+	valOpts.AddFlags(cmd.Flags())
+	_ = cobra.MarkFlagRequired(cmd.Flags(), "input")
+	_ = cmd.RegisterFlagCompletionFunc("input", completeChartInput)
+
+	return cmd
+}
+
+// inspectCRDs lists the CRDs collected from the crds/ directories of ch and its subcharts,
+// like crd-only does, followed by the CRDs rendered by templates.
+func inspectCRDs(ch *chart.Chart, vals map[string]any) ([]inspectedCRD, error) {
+	crdMap := map[schema.GroupKind]*chart.File{}
+	sourceMap := map[schema.GroupKind]string{}
+
+	if err := collectCRDs(os.Stderr, ch, ch.Name(), nil, crdMap, sourceMap); err != nil {
+		return nil, err
+	}
+
+	manifests, err := renderManifests(ch, vals, defaultReleaseOptions(ch))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to render templates, skipping CRDs created by templates: %v\n", err)
+	}
+	for _, m := range manifests {
+		if m.obj.GroupVersionKind().GroupKind() != crdv1.Kind("CustomResourceDefinition") {
+			continue
+		}
+		data, err := yaml.Marshal(m.obj.Object)
+		if err != nil {
+			return nil, err
+		}
+		key, err := extractCRDKey(data)
+		if err != nil {
+			continue
+		}
+		if _, exists := crdMap[*key]; !exists {
+			crdMap[*key] = &chart.File{Name: m.source, Data: data}
+			sourceMap[*key] = m.source
+		}
+	}
+
+	inventory, err := crdInventory(crdMap)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]inspectedCRD, 0, len(inventory))
+	for i, key := range sortedCRDKeys(crdMap) {
+		f := crdMap[key]
+		var obj struct {
+			APIVersion string `json:"apiVersion"`
+		}
+		_ = yaml.Unmarshal(f.Data, &obj)
+		result = append(result, inspectedCRD{
+			crdInventoryEntry: inventory[i],
+			APIVersion:        obj.APIVersion,
+			Size:              len(f.Data),
+			Source:            sourceMap[key],
+			File:              f.Name,
+		})
+	}
+	return result, nil
+}
+
+func printCRDs(out io.Writer, crds []inspectedCRD) {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "GROUP\tKIND\tVERSIONS\tSCOPE\tSIZE\tSOURCE\tFILE")
+	for _, c := range crds {
+		versions := make([]string, 0, len(c.Versions))
+		for _, v := range c.Versions {
+			if v == c.StorageVersion {
+				v += "*"
+			}
+			versions = append(versions, v)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n", c.Group, c.Kind, strings.Join(versions, ","), c.Scope, formatBytes(int64(c.Size)), c.Source, c.File)
+	}
+	_ = w.Flush()
+	fmt.Fprintf(out, "\n%d CRDs, * marks the storage version\n", len(crds))
+}
@@ -0,0 +1,194 @@
+/*
+Copyright AppsCode Inc. and Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmds
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/spf13/pflag"
+	"helm.sh/helm/v3/pkg/chart"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+const (
+	installerTemplatesDir = "templates/crd-installer/"
+	installerCRDsDir      = "files/crd-installer/"
+
+	// maxConfigMapSize is the size limit of a ConfigMap enforced by the API server
+	maxConfigMapSize = 1 << 20
+
+	defaultInstallerImage = "registry.k8s.io/kubectl:v1.33.0"
+)
+
+// installerOptions configures a Job that applies the CRDs with kubectl when the chart is
+// installed or upgraded, instead of relying on the crds/ directory which Helm never upgrades.
+type installerOptions struct {
+	image     string
+	crdsImage string
+}
+
+func newInstallerOptions() installerOptions {
+	return installerOptions{
+		image: defaultInstallerImage,
+	}
+}
+
+func (o *installerOptions) AddFlags(fs *pflag.FlagSet) {
+	fs.StringVar(&o.image, "installer-image", o.image, "kubectl image used by the CRD installer Job (overridable with the crdInstaller.image value)")
+	fs.StringVar(&o.crdsImage, "installer-crds-image", o.crdsImage, "Image providing kubectl and the CRD manifests under /crds, used by the CRD installer Job instead of embedding the CRDs in a ConfigMap")
+}
+
+// files returns the templates of the installer Job and, unless the CRDs are baked into an image,
+// the CRD manifests read by its ConfigMap.
+func (o *installerOptions) files(crdMap map[schema.GroupKind]*chart.File) ([]*chart.File, error) {
+	var files []*chart.File
+	image := o.image
+	if o.crdsImage != "" {
+		image = o.crdsImage
+	} else {
+		size := 0
+		for _, key := range sortedCRDKeys(crdMap) {
+			f := crdMap[key]
+			size += len(f.Data)
+			files = append(files, &chart.File{
+				Name: installerCRDsDir + strings.ToLower(key.Kind) + "." + key.Group + ".yaml",
+				Data: f.Data,
+			})
+		}
+		if size > maxConfigMapSize {
+			return nil, fmt.Errorf("CRDs are %d bytes, exceeding the ConfigMap size limit of %d bytes (use --installer-crds-image)", size, maxConfigMapSize)
+		}
+		files = append(files, &chart.File{
+			Name: installerTemplatesDir + "configmap.yaml",
+			Data: []byte(installerConfigMap),
+		})
+	}
+
+	mount, volume := installerVolumeMount, installerVolume
+	if o.crdsImage != "" {
+		mount, volume = "", ""
+	}
+	files = append(files,
+		&chart.File{Name: installerTemplatesDir + "rbac.yaml", Data: []byte(installerRBAC)},
+		&chart.File{Name: installerTemplatesDir + "job.yaml", Data: []byte(fmt.Sprintf(installerJob, image, mount, volume))},
+	)
+	return files, nil
+}
+
+const installerHookAnnotations = `
+    "helm.sh/hook": pre-install,pre-upgrade
+    "helm.sh/hook-delete-policy": before-hook-creation`
+
+const installerConfigMap = `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: {{ .Release.Name }}-crd-installer
+  namespace: {{ .Release.Namespace }}
+  annotations:` + installerHookAnnotations + `
+    "helm.sh/hook-weight": "-10"
+data:
+{{ (.Files.Glob "` + installerCRDsDir + `*").AsConfig | indent 2 }}
+`
+
+// installerRBAC names the cluster scoped objects after the release namespace too, since releases
+// with the same name may be installed in several namespaces.
+const installerRBAC = `apiVersion: v1
+kind: ServiceAccount
+metadata:
+  name: {{ .Release.Name }}-crd-installer
+  namespace: {{ .Release.Namespace }}
+  annotations:` + installerHookAnnotations + `
+    "helm.sh/hook-weight": "-10"
+---
+apiVersion: rbac.authorization.k8s.io/v1
+kind: ClusterRole
+metadata:
+  name: {{ .Release.Namespace }}-{{ .Release.Name }}-crd-installer
+  annotations:` + installerHookAnnotations + `
+    "helm.sh/hook-weight": "-10"
+rules:
+- apiGroups: ["apiextensions.k8s.io"]
+  resources: ["customresourcedefinitions"]
+  verbs: ["get", "list", "watch", "create", "update", "patch"]
+---
+apiVersion: rbac.authorization.k8s.io/v1
+kind: ClusterRoleBinding
+metadata:
+  name: {{ .Release.Namespace }}-{{ .Release.Name }}-crd-installer
+  annotations:` + installerHookAnnotations + `
+    "helm.sh/hook-weight": "-10"
+roleRef:
+  apiGroup: rbac.authorization.k8s.io
+  kind: ClusterRole
+  name: {{ .Release.Namespace }}-{{ .Release.Name }}-crd-installer
+subjects:
+- kind: ServiceAccount
+  name: {{ .Release.Name }}-crd-installer
+  namespace: {{ .Release.Namespace }}
+`
+
+// installerVolumeMount and installerVolume mount the CRDs ConfigMap into the installer containers.
+const (
+	installerVolumeMount = `
+        volumeMounts:
+        - name: crds
+          mountPath: /crds
+          readOnly: true`
+	installerVolume = `
+      volumes:
+      - name: crds
+        configMap:
+          name: {{ .Release.Name }}-crd-installer`
+)
+
+// installerJob applies the CRDs server-side in an init container, then waits until they are established.
+const installerJob = `apiVersion: batch/v1
+kind: Job
+metadata:
+  name: {{ .Release.Name }}-crd-installer
+  namespace: {{ .Release.Namespace }}
+  annotations:` + installerHookAnnotations + `,hook-succeeded
+    "helm.sh/hook-weight": "0"
+spec:
+  backoffLimit: 3
+  template:
+    spec:
+      serviceAccountName: {{ .Release.Name }}-crd-installer
+      restartPolicy: OnFailure
+      securityContext:
+        runAsNonRoot: true
+        runAsUser: 65534
+        seccompProfile:
+          type: RuntimeDefault
+      initContainers:
+      - name: apply
+        image: {{ dig "crdInstaller" "image" %[1]q .Values.AsMap }}
+        command: ["kubectl", "apply", "--server-side", "--force-conflicts", "--field-manager=crd-installer", "-f", "/crds"]
+        securityContext:
+          allowPrivilegeEscalation: false
+          capabilities:
+            drop: ["ALL"]%[2]s
+      containers:
+      - name: wait
+        image: {{ dig "crdInstaller" "image" %[1]q .Values.AsMap }}
+        command: ["kubectl", "wait", "--for=condition=Established", "--timeout=120s", "-f", "/crds"]
+        securityContext:
+          allowPrivilegeEscalation: false
+          capabilities:
+            drop: ["ALL"]%[2]s%[3]s
+`
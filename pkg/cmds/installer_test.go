@@ -0,0 +1,78 @@
+/*
+Copyright AppsCode Inc. and Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmds
+
+import (
+	"strings"
+	"testing"
+
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/chartutil"
+	"helm.sh/helm/v3/pkg/engine"
+	"sigs.k8s.io/yaml"
+)
+
+func TestInstallerClusterScopedNames(t *testing.T) {
+	opts := installerOptions{image: defaultInstallerImage, crdsImage: "example.com/crds:v1"}
+	files, err := opts.files(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ch := &chart.Chart{
+		Metadata:  &chart.Metadata{APIVersion: chart.APIVersionV2, Name: "demo", Version: "0.1.0"},
+		Templates: files,
+	}
+
+	// The same release installed in two namespaces must not share cluster scoped objects
+	names := map[string]string{}
+	for _, ns := range []string{"team-a", "team-b"} {
+		options := chartutil.ReleaseOptions{Name: "demo", Namespace: ns, Revision: 1, IsInstall: true}
+		vals, err := chartutil.ToRenderValues(ch, nil, options, chartutil.DefaultCapabilities)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rendered, err := engine.Render(ch, vals)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, out := range rendered {
+			for _, doc := range strings.Split(out, "\n---") {
+				var obj struct {
+					Kind     string `json:"kind"`
+					Metadata struct {
+						Name      string `json:"name"`
+						Namespace string `json:"namespace"`
+					} `json:"metadata"`
+				}
+				if err := yaml.Unmarshal([]byte(doc), &obj); err != nil {
+					t.Fatal(err)
+				}
+				if obj.Kind == "" || obj.Metadata.Namespace != "" {
+					continue
+				}
+				key := obj.Kind + "/" + obj.Metadata.Name
+				if prev, ok := names[key]; ok {
+					t.Errorf("%s is created by the releases in %s and %s", key, prev, ns)
+				}
+				names[key] = ns
+			}
+		}
+	}
+	if len(names) != 4 {
+		t.Errorf("expected a ClusterRole and a ClusterRoleBinding per namespace, got %v", names)
+	}
+}
@@ -0,0 +1,160 @@
+/*
+Copyright AppsCode Inc. and Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmds
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+var errSelectionAborted = errors.New("selection aborted")
+
+// selectItem is an entry of an interactive checklist. columns are printed aligned.
+type selectItem struct {
+	columns  []string
+	selected bool
+}
+
+// promptSelection prints items as a numbered checklist and toggles them with the commands read
+// from in, until an empty line or the end of the input:
+//
+//	3 5-7   toggle items 3 and 5 to 7
+//	a       select all items
+//	n       deselect all items
+//	q       abort
+func promptSelection(in *bufio.Reader, out io.Writer, title string, header []string, items []selectItem) error {
+	for {
+		_, _ = fmt.Fprintf(out, "\n%s\n", title)
+		w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+		_, _ = fmt.Fprintf(w, "\t\t%s\n", strings.Join(header, "\t"))
+		for i, item := range items {
+			mark := " "
+			if item.selected {
+				mark = "x"
+			}
+			_, _ = fmt.Fprintf(w, "[%s]\t%d\t%s\n", mark, i+1, strings.Join(item.columns, "\t"))
+		}
+		_ = w.Flush()
+		_, _ = fmt.Fprint(out, "Toggle items (e.g. 1 3-5), a: all, n: none, q: abort, enter: done> ")
+
+		line, err := in.ReadString('\n')
+		if err != nil && !errors.Is(err, io.EOF) {
+			return err
+		}
+		line = strings.TrimSpace(line)
+		if line == "" {
+			_, _ = fmt.Fprintln(out)
+			return nil
+		}
+
+		switch line {
+		case "a", "n":
+			for i := range items {
+				items[i].selected = line == "a"
+			}
+		case "q":
+			return errSelectionAborted
+		default:
+			indexes, perr := parseSelection(line, len(items))
+			if perr != nil {
+				_, _ = fmt.Fprintf(out, "Invalid selection: %v\n", perr)
+			}
+			for _, i := range indexes {
+				items[i].selected = !items[i].selected
+			}
+		}
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+	}
+}
+
+// parseSelection parses item numbers and ranges like "1 3-5,7" into zero based indexes.
+func parseSelection(s string, n int) ([]int, error) {
+	var result []int
+	for _, field := range strings.FieldsFunc(s, func(r rune) bool { return r == ' ' || r == ',' }) {
+		from, to, isRange := strings.Cut(field, "-")
+		start, err := strconv.Atoi(from)
+		if err != nil {
+			return nil, fmt.Errorf("%q is not a number", field)
+		}
+		end := start
+		if isRange {
+			if end, err = strconv.Atoi(to); err != nil {
+				return nil, fmt.Errorf("%q is not a range", field)
+			}
+		}
+		if start < 1 || end > n || start > end {
+			return nil, fmt.Errorf("%q is out of range 1-%d", field, n)
+		}
+		for i := start; i <= end; i++ {
+			result = append(result, i-1)
+		}
+	}
+	return result, nil
+}
+
+// selectTargetContents lets the user choose the CRDs and copyable files of the CRD chart t.
+// Deselected CRDs are removed from t.crdMap and deselected files recorded in t.excludedFiles.
+func selectTargetContents(t *crdTarget, copyFiles bool, in *bufio.Reader, out io.Writer) error {
+	keys := sortedCRDKeys(t.crdMap)
+	items := make([]selectItem, 0, len(keys))
+	for _, key := range keys {
+		items = append(items, selectItem{
+			columns:  []string{key.Kind + "." + key.Group, t.crdSources[key], formatBytes(int64(len(t.crdMap[key].Data)))},
+			selected: true,
+		})
+	}
+	if err := promptSelection(in, out, "CRDs of chart "+t.name+":", []string{"CRD", "SOURCE", "SIZE"}, items); err != nil {
+		return err
+	}
+	for i, key := range keys {
+		if !items[i].selected {
+			delete(t.crdMap, key)
+		}
+	}
+
+	if !copyFiles {
+		return nil
+	}
+	files := copyableFiles(t.chart)
+	if len(files) == 0 {
+		return nil
+	}
+	items = make([]selectItem, 0, len(files))
+	for _, f := range files {
+		items = append(items, selectItem{
+			columns:  []string{f.Name, t.chart.Name(), formatBytes(int64(len(f.Data)))},
+			selected: true,
+		})
+	}
+	if err := promptSelection(in, out, "Files copied into chart "+t.name+":", []string{"FILE", "SOURCE", "SIZE"}, items); err != nil {
+		return err
+	}
+	t.excludedFiles = map[string]bool{}
+	for i, f := range files {
+		if !items[i].selected {
+			t.excludedFiles[f.Name] = true
+		}
+	}
+	return nil
+}
@@ -0,0 +1,134 @@
+/*
+Copyright AppsCode Inc. and Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmds
+
+import (
+	"fmt"
+	"strings"
+
+	"helm.sh/helm/v3/pkg/chart"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	crdInventoryFile          = "crd-inventory.yaml"
+	artifactHubCRDsAnnotation = "artifacthub.io/crds"
+
+	readmeCRDsStart = "<!-- chart-packer:crds:start -->"
+	readmeCRDsEnd   = "<!-- chart-packer:crds:end -->"
+)
+
+// crdInventoryEntry describes a packaged CRD.
+type crdInventoryEntry struct {
+	Name           string   `json:"name"`
+	Group          string   `json:"group"`
+	Kind           string   `json:"kind"`
+	Scope          string   `json:"scope"`
+	Versions       []string `json:"versions"`
+	StorageVersion string   `json:"storageVersion"`
+	// Description of the storage version schema
+	Description string `json:"-"`
+}
+
+// artifactHubCRD is an entry of the artifacthub.io/crds annotation.
+type artifactHubCRD struct {
+	Kind        string `json:"kind"`
+	Version     string `json:"version"`
+	Name        string `json:"name"`
+	DisplayName string `json:"displayName"`
+	Description string `json:"description,omitempty"`
+}
+
+// crdInventory lists the CRDs of crdMap ordered by group and kind.
+func crdInventory(crdMap map[schema.GroupKind]*chart.File) ([]crdInventoryEntry, error) {
+	var result []crdInventoryEntry
+	for _, key := range sortedCRDKeys(crdMap) {
+		crd, err := decodeCRD(crdMap[key].Data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse CRD %s/%s: %v", key.Kind, key.Group, err)
+		}
+		entry := crdInventoryEntry{
+			Name:  crd.Name,
+			Group: crd.Spec.Group,
+			Kind:  crd.Spec.Names.Kind,
+			Scope: string(crd.Spec.Scope),
+		}
+		for _, v := range crd.Spec.Versions {
+			entry.Versions = append(entry.Versions, v.Name)
+			if v.Storage {
+				entry.StorageVersion = v.Name
+				if v.Schema != nil && v.Schema.OpenAPIV3Schema != nil {
+					entry.Description = v.Schema.OpenAPIV3Schema.Description
+				}
+			}
+		}
+		result = append(result, entry)
+	}
+	return result, nil
+}
+
+// crdInventoryFileData encodes the inventory as the crd-inventory.yaml file.
+func crdInventoryFileData(inventory []crdInventoryEntry) ([]byte, error) {
+	return yaml.Marshal(map[string]any{"crds": inventory})
+}
+
+// artifactHubCRDs encodes the inventory as the value of the artifacthub.io/crds annotation.
+func artifactHubCRDs(inventory []crdInventoryEntry) (string, error) {
+	crds := make([]artifactHubCRD, 0, len(inventory))
+	for _, e := range inventory {
+		crds = append(crds, artifactHubCRD{
+			Kind:        e.Kind,
+			Version:     e.StorageVersion,
+			Name:        e.Name,
+			DisplayName: e.Kind,
+			// the first sentence is enough for a summary
+			Description: strings.TrimSpace(strings.SplitN(e.Description, "\n", 2)[0]),
+		})
+	}
+	data, err := yaml.Marshal(crds)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// injectReadmeCRDSection replaces the content between the CRD section markers of a README
+// with a table of the inventory. Without markers, the section is appended to the README.
+func injectReadmeCRDSection(readme []byte, inventory []crdInventoryEntry) []byte {
+	var buf strings.Builder
+	buf.WriteString(readmeCRDsStart + "\n")
+	buf.WriteString("## Custom Resource Definitions\n\n")
+	buf.WriteString("| Kind | Group | Versions | Scope |\n")
+	buf.WriteString("|------|-------|----------|-------|\n")
+	for _, e := range inventory {
+		fmt.Fprintf(&buf, "| %s | %s | %s | %s |\n", e.Kind, e.Group, strings.Join(e.Versions, ", "), e.Scope)
+	}
+	buf.WriteString(readmeCRDsEnd)
+
+	text := string(readme)
+	start := strings.Index(text, readmeCRDsStart)
+	end := strings.Index(text, readmeCRDsEnd)
+	if start >= 0 && end > start {
+		return []byte(text[:start] + buf.String() + text[end+len(readmeCRDsEnd):])
+	}
+	text = strings.TrimRight(text, "\n")
+	if text != "" {
+		text += "\n\n"
+	}
+	return []byte(text + buf.String() + "\n")
+}
@@ -0,0 +1,312 @@
+/*
+Copyright AppsCode Inc. and Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmds
+
+import (
+	"bytes"
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+	"text/template"
+
+	"github.com/spf13/pflag"
+	"helm.sh/helm/v3/pkg/chart"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/yaml"
+)
+
+// metadataOptions customizes the Chart.yaml of the generated chart.
+type metadataOptions struct {
+	description     string
+	keywords        []string
+	replaceKeywords bool
+	home            string
+	sources         []string
+
+	deprecated        bool
+	deprecationNotice string
+
+	changesFile string
+	changes     []string
+
+	enrichFromDoc bool
+	docFields     map[string]string
+
+	copyAnnotations []string
+	dropAnnotations []string
+	nameAnnotations []string
+}
+
+// artifactHubChange is an entry of the artifacthub.io/changes annotation.
+// See https://artifacthub.io/docs/topics/annotations/helm/
+type artifactHubChange struct {
+	Kind        string            `json:"kind"`
+	Description string            `json:"description"`
+	Links       []artifactHubLink `json:"links,omitempty"`
+}
+
+type artifactHubLink struct {
+	Name string `json:"name"`
+	URL  string `json:"url"`
+}
+
+const artifactHubChangesAnnotation = "artifacthub.io/changes"
+
+// docMetadataFields are the Chart.yaml fields that can be filled from doc.yaml.
+var docMetadataFields = []string{"description", "home", "icon", "sources"}
+
+var artifactHubChangeKinds = []string{"added", "changed", "deprecated", "removed", "fixed", "security"}
+
+func newMetadataOptions() metadataOptions {
+	return metadataOptions{
+		deprecationNotice: "This chart is deprecated and will not receive further updates.",
+		docFields: map[string]string{
+			"description": "project.description",
+			"home":        "project.url",
+			"icon":        "project.icon",
+			"sources":     "project.sources",
+		},
+	}
+}
+
+func (o *metadataOptions) AddFlags(fs *pflag.FlagSet) {
+	fs.StringVar(&o.description, "description", o.description, "Go template for the description of the generated chart, e.g. '{{ .Name }} {{ .Version }} CRDs'. The input chart metadata is available as template data")
+	fs.StringSliceVar(&o.keywords, "keyword", o.keywords, "Keyword to add to the generated chart (repeatable)")
+	fs.BoolVar(&o.replaceKeywords, "replace-keywords", o.replaceKeywords, "If true, replace the keywords of the input chart with the ones passed via --keyword")
+	fs.StringVar(&o.home, "home", o.home, "Home URL of the generated chart, replacing the one of the input chart")
+	fs.StringSliceVar(&o.sources, "source", o.sources, "Source URL of the generated chart, replacing the ones of the input chart (repeatable)")
+	fs.BoolVar(&o.deprecated, "deprecated", o.deprecated, "If true, mark the generated chart as deprecated")
+	fs.StringVar(&o.deprecationNotice, "deprecation-notice", o.deprecationNotice, "Notice appended to the description of a deprecated chart")
+	fs.StringVar(&o.changesFile, "changes-file", o.changesFile, "Path to a YAML file listing the changes of this release, used for the artifacthub.io/changes annotation")
+	fs.BoolVar(&o.enrichFromDoc, "enrich-from-doc", o.enrichFromDoc, "If true, fill empty description, home, icon and sources of the generated Chart.yaml from the doc.yaml of the input chart")
+	fs.StringToStringVar(&o.docFields, "doc-field", o.docFields, "Chart.yaml field to doc.yaml path mapping used by --enrich-from-doc, e.g. home=project.url")
+	fs.StringSliceVar(&o.copyAnnotations, "copy-annotation", o.copyAnnotations, "Glob pattern of input chart annotations to copy to the generated chart, e.g. artifacthub.io/*, where * also matches / (repeatable, defaults to all)")
+	fs.StringSliceVar(&o.dropAnnotations, "drop-annotation", o.dropAnnotations, "Glob pattern of input chart annotations not to copy to the generated chart, e.g. internal.example.com/*, where * also matches / (repeatable)")
+	fs.StringSliceVar(&o.nameAnnotations, "name-annotation", o.nameAnnotations, "Annotation whose value is rewritten to the generated chart name if the input chart sets it, e.g. catalog.cattle.io/display-name, in addition to "+strings.Join(defaultNameAnnotations, ", ")+" (repeatable)")
+	fs.StringArrayVar(&o.changes, "change", o.changes, "Change of this release for the artifacthub.io/changes annotation as <kind>:<description>, e.g. 'added:Support X, Y and Z' (repeatable, use --changes-file for links)")
+}
+
+// apply updates md, the metadata of the generated chart, using src, the metadata of the input chart.
+func (o *metadataOptions) apply(md, src *chart.Metadata) error {
+	if err := o.filterAnnotations(md); err != nil {
+		return err
+	}
+
+	if o.description != "" {
+		desc, err := executeTemplate("description", o.description, src)
+		if err != nil {
+			return err
+		}
+		md.Description = desc
+	}
+
+	if o.replaceKeywords {
+		md.Keywords = slices.Clone(o.keywords)
+	} else if len(o.keywords) > 0 {
+		keywords := slices.Clone(md.Keywords)
+		for _, k := range o.keywords {
+			if !slices.Contains(keywords, k) {
+				keywords = append(keywords, k)
+			}
+		}
+		md.Keywords = keywords
+	}
+
+	if o.home != "" {
+		md.Home = o.home
+	}
+	if len(o.sources) > 0 {
+		md.Sources = slices.Clone(o.sources)
+	}
+
+	if o.deprecated {
+		md.Deprecated = true
+		if o.deprecationNotice != "" {
+			desc := strings.TrimSpace(md.Description)
+			if desc != "" && !strings.HasSuffix(desc, ".") {
+				desc += "."
+			}
+			md.Description = strings.TrimSpace(desc + " " + o.deprecationNotice)
+		}
+	}
+
+	changes, err := o.loadChanges()
+	if err != nil {
+		return err
+	}
+	if len(changes) > 0 {
+		data, err := yaml.Marshal(changes)
+		if err != nil {
+			return err
+		}
+		setAnnotation(md, artifactHubChangesAnnotation, string(data))
+	}
+	return nil
+}
+
+// enrich fills the empty fields of md from the doc.yaml content in doc, following the
+// --doc-field mapping. The names of the fields filled are returned.
+func (o *metadataOptions) enrich(md *chart.Metadata, doc []byte) ([]string, error) {
+	if !o.enrichFromDoc || doc == nil {
+		return nil, nil
+	}
+	var content map[string]any
+	if err := yaml.Unmarshal(doc, &content); err != nil {
+		return nil, fmt.Errorf("failed to parse doc.yaml: %v", err)
+	}
+
+	var filled []string
+	for _, field := range slices.Sorted(maps.Keys(o.docFields)) {
+		if !slices.Contains(docMetadataFields, field) {
+			return nil, fmt.Errorf("unsupported --doc-field %q, must be one of %s", field, strings.Join(docMetadataFields, ", "))
+		}
+		v, found, err := unstructured.NestedFieldNoCopy(content, strings.Split(o.docFields[field], ".")...)
+		if err != nil || !found {
+			continue
+		}
+		var values []string
+		switch v := v.(type) {
+		case string:
+			values = []string{v}
+		case []any:
+			for _, e := range v {
+				if s, ok := e.(string); ok {
+					values = append(values, s)
+				}
+			}
+		}
+		if len(values) == 0 || values[0] == "" {
+			continue
+		}
+
+		switch field {
+		case "description":
+			if md.Description != "" {
+				continue
+			}
+			md.Description = values[0]
+		case "home":
+			if md.Home != "" {
+				continue
+			}
+			md.Home = values[0]
+		case "icon":
+			if md.Icon != "" {
+				continue
+			}
+			md.Icon = values[0]
+		case "sources":
+			if len(md.Sources) > 0 {
+				continue
+			}
+			md.Sources = values
+		}
+		filled = append(filled, field)
+	}
+	return filled, nil
+}
+
+// loadChanges collects the changes from --changes-file followed by the ones passed via --change.
+func (o *metadataOptions) loadChanges() ([]artifactHubChange, error) {
+	var changes []artifactHubChange
+	if o.changesFile != "" {
+		data, err := os.ReadFile(o.changesFile)
+		if err != nil {
+			return nil, err
+		}
+		if err := yaml.Unmarshal(data, &changes); err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %v", o.changesFile, err)
+		}
+	}
+	for _, c := range o.changes {
+		// split on the first colon only, so the description may contain any character
+		kind, description, ok := strings.Cut(c, ":")
+		if !ok {
+			return nil, fmt.Errorf("invalid change %q, expected <kind>:<description>", c)
+		}
+		changes = append(changes, artifactHubChange{Kind: strings.TrimSpace(kind), Description: strings.TrimSpace(description)})
+	}
+	for _, c := range changes {
+		if !slices.Contains(artifactHubChangeKinds, c.Kind) {
+			return nil, fmt.Errorf("invalid change kind %q, must be one of %s", c.Kind, strings.Join(artifactHubChangeKinds, ", "))
+		}
+		if c.Description == "" {
+			return nil, fmt.Errorf("change of kind %q is missing a description", c.Kind)
+		}
+	}
+	return changes, nil
+}
+
+// nameAnnotationKeys returns the annotations renameChart rewrites to the generated chart name.
+func (o *metadataOptions) nameAnnotationKeys() []string {
+	return append(slices.Clone(defaultNameAnnotations), o.nameAnnotations...)
+}
+
+// filterAnnotations removes the annotations inherited from the input chart that do not match
+// --copy-annotation, if set, or match --drop-annotation.
+func (o *metadataOptions) filterAnnotations(md *chart.Metadata) error {
+	if len(o.copyAnnotations) == 0 && len(o.dropAnnotations) == 0 {
+		return nil
+	}
+	annotations := map[string]string{}
+	for k, v := range md.Annotations {
+		keep := true
+		if len(o.copyAnnotations) > 0 {
+			matched, err := matchKeyAny(o.copyAnnotations, k)
+			if err != nil {
+				return err
+			}
+			keep = matched
+		}
+		dropped, err := matchKeyAny(o.dropAnnotations, k)
+		if err != nil {
+			return err
+		}
+		if keep && !dropped {
+			annotations[k] = v
+		}
+	}
+	if len(annotations) == 0 {
+		annotations = nil
+	}
+	md.Annotations = annotations
+	return nil
+}
+
+// setAnnotation sets an annotation on md without modifying a map shared with the input chart.
+func setAnnotation(md *chart.Metadata, key, value string) {
+	annotations := maps.Clone(md.Annotations)
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+	annotations[key] = value
+	md.Annotations = annotations
+}
+
+func executeTemplate(name, text string, data any) (string, error) {
+	tpl, err := template.New(name).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse %s template: %v", name, err)
+	}
+	var buf bytes.Buffer
+	if err := tpl.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("failed to execute %s template: %v", name, err)
+	}
+	return buf.String(), nil
+}
@@ -0,0 +1,171 @@
+/*
+Copyright AppsCode Inc. and Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmds
+
+import (
+	"maps"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"helm.sh/helm/v3/pkg/chart"
+)
+
+func TestLoadChanges(t *testing.T) {
+	changesFile := filepath.Join(t.TempDir(), "changes.yaml")
+	if err := os.WriteFile(changesFile, []byte(`- kind: fixed
+  description: Fix the webhook
+  links:
+  - name: PR
+    url: https://example.com/pr/1
+`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name        string
+		changesFile string
+		changes     []string
+		want        []artifactHubChange
+		wantErr     bool
+	}{
+		{
+			name:    "kind and description",
+			changes: []string{"added:Support X"},
+			want:    []artifactHubChange{{Kind: "added", Description: "Support X"}},
+		},
+		{
+			name:    "description with separators",
+			changes: []string{"changed: Use {group}: v1, v2 and v3"},
+			want:    []artifactHubChange{{Kind: "changed", Description: "Use {group}: v1, v2 and v3"}},
+		},
+		{
+			name:        "file first",
+			changesFile: changesFile,
+			changes:     []string{"security:Bump Go"},
+			want: []artifactHubChange{
+				{Kind: "fixed", Description: "Fix the webhook", Links: []artifactHubLink{{Name: "PR", URL: "https://example.com/pr/1"}}},
+				{Kind: "security", Description: "Bump Go"},
+			},
+		},
+		{
+			name:    "missing separator",
+			changes: []string{"Support X"},
+			wantErr: true,
+		},
+		{
+			name:    "invalid kind",
+			changes: []string{"new:Support X"},
+			wantErr: true,
+		},
+		{
+			name:    "missing description",
+			changes: []string{"added: "},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o := &metadataOptions{changesFile: tt.changesFile, changes: tt.changes}
+			got, err := o.loadChanges()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("loadChanges() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("loadChanges() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFilterAnnotations(t *testing.T) {
+	annotations := map[string]string{
+		"artifacthub.io/changes":   "- Fix the webhook",
+		"artifacthub.io/license":   "Apache-2.0",
+		"charts.openshift.io/name": "Demo",
+		"category":                 "Database",
+	}
+
+	tests := []struct {
+		name            string
+		copyAnnotations []string
+		dropAnnotations []string
+		want            map[string]string
+		wantErr         bool
+	}{
+		{
+			name: "no filters",
+			want: annotations,
+		},
+		{
+			name:            "copy globs",
+			copyAnnotations: []string{"artifacthub.io/*", "category"},
+			want: map[string]string{
+				"artifacthub.io/changes": "- Fix the webhook",
+				"artifacthub.io/license": "Apache-2.0",
+				"category":               "Database",
+			},
+		},
+		{
+			name:            "drop globs",
+			dropAnnotations: []string{"artifacthub.io/*"},
+			want: map[string]string{
+				"charts.openshift.io/name": "Demo",
+				"category":                 "Database",
+			},
+		},
+		{
+			name:            "star matches prefixes",
+			copyAnnotations: []string{"*"},
+			dropAnnotations: []string{"*name"},
+			want: map[string]string{
+				"artifacthub.io/changes": "- Fix the webhook",
+				"artifacthub.io/license": "Apache-2.0",
+				"category":               "Database",
+			},
+		},
+		{
+			name:            "drop wins over copy",
+			copyAnnotations: []string{"artifacthub.io/*"},
+			dropAnnotations: []string{"artifacthub.io/changes"},
+			want:            map[string]string{"artifacthub.io/license": "Apache-2.0"},
+		},
+		{
+			name:            "all dropped",
+			dropAnnotations: []string{"*", "*/*"},
+		},
+		{
+			name:            "invalid pattern",
+			copyAnnotations: []string{"["},
+			wantErr:         true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o := &metadataOptions{copyAnnotations: tt.copyAnnotations, dropAnnotations: tt.dropAnnotations}
+			md := &chart.Metadata{Annotations: maps.Clone(annotations)}
+			err := o.filterAnnotations(md)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("filterAnnotations() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(md.Annotations, tt.want) {
+				t.Errorf("filterAnnotations() = %v, want %v", md.Annotations, tt.want)
+			}
+		})
+	}
+}
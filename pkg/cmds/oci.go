@@ -0,0 +1,121 @@
+/*
+Copyright AppsCode Inc. and Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmds
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"helm.sh/helm/v3/pkg/registry"
+)
+
+const ociInputPrefix = "oci://"
+
+// splitOCIRef splits oci://registry/repo/chart:version into the chart reference and the version.
+func splitOCIRef(input string) (ref, version string, err error) {
+	ref = input
+	if idx := strings.LastIndex(ref, ":"); idx > strings.LastIndex(ref, "/") {
+		ref, version = ref[:idx], ref[idx+1:]
+	}
+	if version == "" || !strings.Contains(strings.TrimPrefix(ref, ociInputPrefix), "/") {
+		return "", "", fmt.Errorf("invalid oci input %q: expected oci://<registry>/<repo>/<chart>:<version>", input)
+	}
+	return ref, version, nil
+}
+
+// newRegistryClient returns a helm registry client using the credentials stored by
+// `helm registry login` at $HELM_REGISTRY_CONFIG or the helm config home, with the docker
+// credential stores and helpers as fallback.
+func newRegistryClient(plainHTTP bool, options ...registry.ClientOption) (*registry.Client, error) {
+	options = append(options, registry.ClientOptEnableCache(true))
+	if filename := os.Getenv("HELM_REGISTRY_CONFIG"); filename != "" {
+		options = append(options, registry.ClientOptCredentialsFile(filename))
+	}
+	if plainHTTP {
+		options = append(options, registry.ClientOptPlainHTTP())
+	}
+	return registry.NewClient(options...)
+}
+
+// listOCITags lists the tags of oci://registry/repo/chart.
+func listOCITags(ref string, plainHTTP bool) ([]string, error) {
+	client, err := newRegistryClient(plainHTTP, registry.ClientOptHTTPClient(&http.Client{Timeout: 10 * time.Second}))
+	if err != nil {
+		return nil, err
+	}
+	return client.Tags(strings.TrimPrefix(ref, ociInputPrefix))
+}
+
+// pullOCIChart downloads the chart archive from an OCI registry. The cosign signature is verified
+// against the digest of the pulled manifest, so a tag moved in between can not swap the verified
+// chart.
+func (o *inputOptions) pullOCIChart(input string) ([]byte, error) {
+	ref, _, err := splitOCIRef(input)
+	if err != nil {
+		return nil, err
+	}
+	client, err := newRegistryClient(o.plainHTTP)
+	if err != nil {
+		return nil, err
+	}
+	result, err := client.Pull(strings.TrimPrefix(input, ociInputPrefix))
+	if err != nil {
+		return nil, fmt.Errorf("failed to pull %s: %v", input, err)
+	}
+	if o.verifySignature {
+		if err := o.verifyCosignSignature(strings.TrimPrefix(ref, ociInputPrefix) + "@" + result.Manifest.Digest); err != nil {
+			return nil, err
+		}
+	}
+	return result.Chart.Data, nil
+}
+
+// verifyCosignSignature runs cosign verify against image using either a public key
+// or keyless certificate identity and issuer constraints.
+func (o *inputOptions) verifyCosignSignature(image string) error {
+	args := []string{"verify", "--output", "text"}
+	switch {
+	case o.cosignKey != "":
+		args = append(args, "--key", o.cosignKey)
+	case (o.certIdentity != "" || o.certIdentityRegexp != "") && o.certOIDCIssuer != "":
+		if o.certIdentity != "" {
+			args = append(args, "--certificate-identity", o.certIdentity)
+		} else {
+			args = append(args, "--certificate-identity-regexp", o.certIdentityRegexp)
+		}
+		args = append(args, "--certificate-oidc-issuer", o.certOIDCIssuer)
+	default:
+		return fmt.Errorf("--verify-signature requires --cosign-key or --certificate-identity(-regexp) with --certificate-oidc-issuer")
+	}
+	args = append(args, image)
+
+	if _, err := exec.LookPath("cosign"); err != nil {
+		return fmt.Errorf("--verify-signature requires the cosign binary in PATH: %v", err)
+	}
+	c := exec.Command("cosign", args...)
+	c.Stdout = os.Stderr
+	c.Stderr = os.Stderr
+	if err := c.Run(); err != nil {
+		return fmt.Errorf("failed to verify cosign signature of %s: %v", image, err)
+	}
+	o.logf("Verified cosign signature of %s\n", image)
+	return nil
+}
@@ -0,0 +1,112 @@
+/*
+Copyright AppsCode Inc. and Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmds
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+
+	"helm.sh/helm/v3/pkg/registry"
+)
+
+func testDigest(data []byte) string {
+	sum := sha256.Sum256(data)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+// testOCIRegistry serves a chart archive under the tag 1.0.0_build over plain HTTP, requiring the
+// basic auth credentials stored in $HELM_REGISTRY_CONFIG. It returns the chart reference.
+func testOCIRegistry(t *testing.T, archive []byte) string {
+	config := []byte(`{"name":"demo","version":"1.0.0+build"}`)
+	manifest := []byte(fmt.Sprintf(`{"schemaVersion":2,"mediaType":%q,"config":{"mediaType":%q,"digest":%q,"size":%d},"layers":[{"mediaType":%q,"digest":%q,"size":%d}]}`,
+		"application/vnd.oci.image.manifest.v1+json",
+		registry.ConfigMediaType, testDigest(config), len(config),
+		registry.ChartLayerMediaType, testDigest(archive), len(archive)))
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, password, ok := r.BasicAuth(); !ok || user != "user" || password != "secret" {
+			w.Header().Set("WWW-Authenticate", `Basic realm="test"`)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		var data []byte
+		switch r.URL.Path {
+		case "/v2/charts/demo/tags/list":
+			data = []byte(`{"name":"charts/demo","tags":["1.0.0_build","latest"]}`)
+			w.Header().Set("Content-Type", "application/json")
+		case "/v2/charts/demo/manifests/1.0.0_build", "/v2/charts/demo/manifests/" + testDigest(manifest):
+			data = manifest
+			w.Header().Set("Content-Type", "application/vnd.oci.image.manifest.v1+json")
+			w.Header().Set("Docker-Content-Digest", testDigest(manifest))
+		case "/v2/charts/demo/blobs/" + testDigest(config):
+			data = config
+		case "/v2/charts/demo/blobs/" + testDigest(archive):
+			data = archive
+		default:
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+		if r.Method != http.MethodHead {
+			_, _ = w.Write(data)
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	host := strings.TrimPrefix(srv.URL, "http://")
+	auth := base64.StdEncoding.EncodeToString([]byte("user:secret"))
+	credentials := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(credentials, []byte(fmt.Sprintf(`{"auths":{%q:{"auth":%q}}}`, host, auth)), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("HELM_REGISTRY_CONFIG", credentials)
+	t.Setenv("DOCKER_CONFIG", t.TempDir())
+	return ociInputPrefix + host + "/charts/demo"
+}
+
+func TestPullOCIChart(t *testing.T) {
+	archive := []byte("chart archive")
+	ref := testOCIRegistry(t, archive)
+
+	o := inputOptions{plainHTTP: true}
+	data, err := o.pullOCIChart(ref + ":1.0.0+build")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(data, archive) {
+		t.Errorf("pulled archive = %q, want %q", data, archive)
+	}
+
+	tags, err := listOCITags(ref, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"1.0.0+build"}; !reflect.DeepEqual(tags, want) {
+		t.Errorf("tags = %v, want %v", tags, want)
+	}
+}
@@ -0,0 +1,88 @@
+/*
+Copyright AppsCode Inc. and Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmds
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// progress reports the phases of a run, with their timing, and the files written to stderr,
+// keeping stdout for the regular output.
+var progress struct {
+	enabled bool
+	start   time.Time
+}
+
+func enableProgress() {
+	progress.enabled = true
+	progress.start = time.Now()
+}
+
+func progressf(format string, args ...any) {
+	if !progress.enabled {
+		return
+	}
+	elapsed := time.Since(progress.start).Seconds()
+	_, _ = fmt.Fprintf(os.Stderr, "[%7.2fs] %s\n", elapsed, fmt.Sprintf(format, args...))
+}
+
+// spanLabel formats the name and attribute values of s, e.g. "save demo-crds".
+func spanLabel(s *span) string {
+	parts := []string{s.name}
+	for _, kv := range s.attrs {
+		parts = append(parts, kv[1])
+	}
+	return strings.Join(parts, " ")
+}
+
+// reportWritten reports the number and size of the files written under dir.
+func reportWritten(dir string) {
+	if !progress.enabled {
+		return
+	}
+	var files int
+	var size int64
+	_ = filepath.WalkDir(dir, func(_ string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		if fi, err := d.Info(); err == nil {
+			files++
+			size += fi.Size()
+		}
+		return nil
+	})
+	progressf("Wrote %d files (%s) to %s", files, formatBytes(size), dir)
+}
+
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
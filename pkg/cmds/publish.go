@@ -0,0 +1,171 @@
+/*
+Copyright AppsCode Inc. and Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmds
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"helm.sh/helm/v3/pkg/chart"
+)
+
+func NewCmdPublish() *cobra.Command {
+	var (
+		inputs   []string
+		gitRepo  string
+		branch   = "gh-pages"
+		repoPath = "."
+		chartURL string
+		message  string
+
+		compression = compressionGzip
+		ckptOpts    checkpointOptions
+	)
+	cmd := &cobra.Command{
+		Use:                   "publish",
+		Short:                 "Publish a chart to a git based chart repository",
+		Long:                  "Clones the chart repository, adds the packaged chart, regenerates index.yaml, then commits and pushes the change.",
+		DisableFlagsInUseLine: true,
+		DisableAutoGenTag:     true,
+		Run: func(cmd *cobra.Command, args []string) {
+			if !slices.Contains(compressions, compression) {
+				fmt.Printf("Error: unsupported --compression %q, must be one of %s\n", compression, strings.Join(compressions, ", "))
+				exit(1)
+			}
+			ckpt, err := ckptOpts.open()
+			if err != nil {
+				fmt.Printf("Error opening checkpoint: %v\n", err)
+				exit(1)
+			}
+			for _, input := range inputs {
+				// Inputs completed by an interrupted run are skipped before they are loaded
+				digest, err := inputDigest(input, "")
+				if err != nil {
+					fmt.Printf("Error reading chart %s: %v\n", input, err)
+					exit(1)
+				}
+				if ckpt.completed(checkpointPublished, input, digest) != nil {
+					fmt.Printf("Skipping chart %s, already published by the interrupted run\n", input)
+					continue
+				}
+				ch, err := loadChart(input)
+				if err != nil {
+					fmt.Printf("Error loading chart %s: %v\n", input, err)
+					exit(1)
+				}
+				if err := publishChart(ch, gitRepo, branch, repoPath, chartURL, message, compression); err != nil {
+					fmt.Printf("Error publishing chart %s: %v\n", input, err)
+					exit(1)
+				}
+				entry := checkpointEntry{Input: input, Digest: digest, Step: checkpointPublished, Charts: []string{ch.Name() + "-" + ch.Metadata.Version}}
+				if err := ckpt.record(entry); err != nil {
+					fmt.Printf("Error updating checkpoint: %v\n", err)
+					exit(1)
+				}
+			}
+		},
+	}
+
+	cmd.Flags().StringSliceVar(&inputs, "input", inputs, "Path to the chart directory or .tgz, .tar.zst or .tar file to publish (repeatable, each chart is pushed in its own commit)")
+	cmd.Flags().StringVar(&gitRepo, "git-repo", "", "URL of the git repository hosting the chart repository")
+	cmd.Flags().StringVar(&branch, "branch", branch, "Branch of the git repository hosting the chart repository")
+	cmd.Flags().StringVar(&repoPath, "path", repoPath, "Directory inside the git repository containing index.yaml")
+	cmd.Flags().StringVar(&chartURL, "chart-url", "", "Base URL of the chart repository, used for the chart URLs in index.yaml")
+	cmd.Flags().StringVar(&message, "message", "", "Commit message (defaults to 'Publish <chart>-<version>')")
+	cmd.Flags().StringVar(&compression, "compression", compression, "Compression of the published chart archive: gzip, zstd (.tar.zst) or none (.tar). Only gzip archives are added to index.yaml")
+	_ = cobra.MarkFlagRequired(cmd.Flags(), "input")
+	_ = cmd.RegisterFlagCompletionFunc("input", completeChartInput)
+	_ = cobra.MarkFlagRequired(cmd.Flags(), "git-repo")
+	ckptOpts.AddFlags(cmd.Flags())
+
+	return cmd
+}
+
+func publishChart(ch *chart.Chart, gitRepo, branch, repoPath, baseURL, message, compression string) error {
+	defer startSpan("push", "repository", gitRepo).End()
+
+	workDir, err := os.MkdirTemp("", "chart-packer-publish-")
+	if err != nil {
+		return err
+	}
+	defer func() { _ = os.RemoveAll(workDir) }()
+
+	repoDir := filepath.Join(workDir, "repo")
+	if err := runGit("", "clone", "--depth", "1", "--branch", branch, gitRepo, repoDir); err != nil {
+		return err
+	}
+
+	chartsDir := filepath.Join(repoDir, repoPath)
+	if err := os.MkdirAll(chartsDir, 0o755); err != nil {
+		return err
+	}
+	archive, err := saveArchive(ch, chartsDir, compression)
+	if err != nil {
+		return fmt.Errorf("failed to package chart: %v", err)
+	}
+	// helm repositories can only serve gzip compressed archives
+	if compression == compressionGzip {
+		data, err := os.ReadFile(archive)
+		if err != nil {
+			return err
+		}
+		indexFile := filepath.Join(chartsDir, "index.yaml")
+		index, err := loadRepoIndex(indexFile)
+		if err != nil {
+			return fmt.Errorf("failed to load %s: %v", indexFile, err)
+		}
+		index.add(ch.Metadata, data, chartURL(baseURL, filepath.Base(archive)))
+		if err := index.write(indexFile); err != nil {
+			return err
+		}
+	} else {
+		fmt.Printf("Not adding %s to index.yaml, Helm only installs gzip compressed chart archives from a repository\n", filepath.Base(archive))
+	}
+
+	if message == "" {
+		message = fmt.Sprintf("Publish %s-%s", ch.Name(), ch.Metadata.Version)
+	}
+	if err := runGit(repoDir, "add", "--all"); err != nil {
+		return err
+	}
+	if err := runGit(repoDir, "commit", "--message", message); err != nil {
+		return err
+	}
+	if err := runGit(repoDir, "push", "origin", branch); err != nil {
+		return err
+	}
+
+	fmt.Printf("Published %s to %s (branch %s)\n", filepath.Base(archive), gitRepo, branch)
+	return nil
+}
+
+// runGit runs a git command in dir, streaming its output to stderr.
+func runGit(dir string, args ...string) error {
+	c := exec.Command("git", args...)
+	c.Dir = dir
+	c.Stdout = os.Stderr
+	c.Stderr = os.Stderr
+	if err := c.Run(); err != nil {
+		return fmt.Errorf("git %s: %v", args[0], err)
+	}
+	return nil
+}
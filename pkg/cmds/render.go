@@ -0,0 +1,202 @@
+/*
+Copyright AppsCode Inc. and Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmds
+
+import (
+	"bytes"
+	"fmt"
+	"path"
+	"slices"
+	"strings"
+
+	"github.com/mitchellh/copystructure"
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/chartutil"
+	"helm.sh/helm/v3/pkg/engine"
+)
+
+// hasTemplateMarkers reports whether data contains Go-template actions.
+// Helm never renders files under crds/, so such markers end up as literal text.
+func hasTemplateMarkers(data []byte) bool {
+	i := bytes.Index(data, []byte("{{"))
+	return i >= 0 && bytes.Contains(data[i:], []byte("}}"))
+}
+
+// templatedCRDs returns the CRD files of ch and its subcharts that contain template markers.
+func templatedCRDs(ch *chart.Chart) []chart.CRD {
+	var result []chart.CRD
+	for _, crd := range ch.CRDObjects() {
+		if hasTemplateMarkers(crd.File.Data) {
+			result = append(result, crd)
+		}
+	}
+	return result
+}
+
+// Modes for CRD files carrying Go-template expressions, which Helm installs without rendering.
+const (
+	crdTemplatesError  = "error"
+	crdTemplatesWarn   = "warn"
+	crdTemplatesRender = "render"
+)
+
+var crdTemplatesModes = []string{crdTemplatesError, crdTemplatesWarn, crdTemplatesRender}
+
+// processCRDTemplates detects CRD files carrying Go-template expressions. Depending on mode it
+// fails, warns, or renders them in place using vals merged on top of the chart defaults.
+func processCRDTemplates(ch *chart.Chart, mode string, vals map[string]any) error {
+	if !slices.Contains(crdTemplatesModes, mode) {
+		return fmt.Errorf("unsupported --crd-templates %q, must be one of %s", mode, strings.Join(crdTemplatesModes, ", "))
+	}
+	crds := templatedCRDs(ch)
+	if len(crds) == 0 {
+		return nil
+	}
+	switch mode {
+	case crdTemplatesError:
+		names := make([]string, 0, len(crds))
+		for _, crd := range crds {
+			names = append(names, crd.Filename)
+		}
+		return fmt.Errorf("CRD files contain template expressions which Helm does not render (use --crd-templates=render):\n  - %s", strings.Join(names, "\n  - "))
+	case crdTemplatesWarn:
+		for _, crd := range crds {
+			fmt.Printf("Warning: CRD file %s contains template expressions which Helm does not render (use --crd-templates=render)\n", crd.Filename)
+		}
+		return nil
+	}
+
+	rendered, err := renderCRDFiles(ch, vals)
+	if err != nil {
+		return err
+	}
+	for _, crd := range crds {
+		out, ok := rendered[crd.Filename]
+		if !ok {
+			return fmt.Errorf("failed to render CRD file %s", crd.Filename)
+		}
+		crd.File.Data = []byte(strings.TrimSpace(out) + "\n")
+		fmt.Printf("Rendered template expressions in CRD file %s\n", crd.Filename)
+	}
+	return nil
+}
+
+// renderCRDFiles renders the crds/ files of ch and its subcharts as if they were templates.
+// The result is keyed by the same path as chart.CRD.Filename. Unlike renderChart, subcharts
+// disabled by their condition or tags are rendered too, as their CRD files are still packaged.
+func renderCRDFiles(ch *chart.Chart, vals map[string]any) (map[string]string, error) {
+	shadow := shadowChart(ch, func(c *chart.Chart) []*chart.File {
+		var files []*chart.File
+		for _, f := range c.Files {
+			if strings.HasPrefix(f.Name, "crds/") && hasTemplateMarkers(f.Data) {
+				files = append(files, f)
+			}
+		}
+		return files
+	})
+	top, err := chartutil.ToRenderValues(shadow, vals, defaultReleaseOptions(ch), chartutil.DefaultCapabilities)
+	if err != nil {
+		return nil, err
+	}
+	return engine.Render(shadow, top)
+}
+
+// shadowChart builds a copy of the chart tree that shares metadata, values and files with ch,
+// but whose templates are limited to the helpers plus the files returned by extra.
+func shadowChart(ch *chart.Chart, extra func(c *chart.Chart) []*chart.File) *chart.Chart {
+	out := &chart.Chart{
+		Metadata: ch.Metadata,
+		Lock:     ch.Lock,
+		Values:   ch.Values,
+		Files:    ch.Files,
+	}
+	for _, f := range ch.Templates {
+		if strings.HasPrefix(path.Base(f.Name), "_") {
+			out.Templates = append(out.Templates, f)
+		}
+	}
+	out.Templates = append(out.Templates, extra(ch)...)
+	for _, dep := range ch.Dependencies() {
+		out.AddDependency(shadowChart(dep, extra))
+	}
+	return out
+}
+
+// defaultReleaseOptions installs ch as a release named after the chart in the default namespace.
+func defaultReleaseOptions(ch *chart.Chart) chartutil.ReleaseOptions {
+	return chartutil.ReleaseOptions{
+		Name:      ch.Name(),
+		Namespace: "default",
+		Revision:  1,
+		IsInstall: true,
+	}
+}
+
+// renderChart renders all templates of ch with the given user supplied values merged
+// on top of the chart defaults. Like helm install, subcharts disabled by their condition or tags
+// are not rendered and import-values are applied, on a copy of ch.
+func renderChart(ch *chart.Chart, vals map[string]any, options chartutil.ReleaseOptions) (map[string]string, error) {
+	ch, err := copyChartTree(ch)
+	if err != nil {
+		return nil, err
+	}
+	if err := chartutil.ProcessDependenciesWithMerge(ch, vals); err != nil {
+		return nil, err
+	}
+	top, err := chartutil.ToRenderValues(ch, vals, options, chartutil.DefaultCapabilities)
+	if err != nil {
+		return nil, err
+	}
+	return engine.Render(ch, top)
+}
+
+// copyChartTree copies ch and its subcharts deep enough for chartutil.ProcessDependencies,
+// which rewrites the dependencies, their enabled state and the values of the charts it processes.
+func copyChartTree(ch *chart.Chart) (*chart.Chart, error) {
+	md := *ch.Metadata
+	md.Dependencies = make([]*chart.Dependency, 0, len(ch.Metadata.Dependencies))
+	for _, d := range ch.Metadata.Dependencies {
+		dep := *d
+		md.Dependencies = append(md.Dependencies, &dep)
+	}
+	if ch.Metadata.Dependencies == nil {
+		md.Dependencies = nil
+	}
+	out := &chart.Chart{
+		Metadata:  &md,
+		Lock:      ch.Lock,
+		Templates: ch.Templates,
+		Schema:    ch.Schema,
+		Files:     ch.Files,
+		Raw:       ch.Raw,
+	}
+	if ch.Values != nil {
+		values, err := copystructure.Copy(ch.Values)
+		if err != nil {
+			return nil, err
+		}
+		out.Values = values.(map[string]any)
+	}
+	for _, dep := range ch.Dependencies() {
+		c, err := copyChartTree(dep)
+		if err != nil {
+			return nil, err
+		}
+		out.AddDependency(c)
+	}
+	return out, nil
+}
@@ -0,0 +1,164 @@
+/*
+Copyright AppsCode Inc. and Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmds
+
+import (
+	"strings"
+	"testing"
+
+	"helm.sh/helm/v3/pkg/chart"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+const configMapTemplate = `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: {{ .Chart.Name }}
+data:
+  value: {{ .Values.value | quote }}
+`
+
+// testChartWithSubchart returns a chart with a subchart enabled by the sub.enabled value,
+// which exports its value to the parent with import-values.
+func testChartWithSubchart(subEnabled bool) *chart.Chart {
+	sub := &chart.Chart{
+		Metadata:  &chart.Metadata{APIVersion: chart.APIVersionV2, Name: "sub", Version: "0.1.0"},
+		Templates: []*chart.File{{Name: "templates/cm.yaml", Data: []byte(configMapTemplate)}},
+		Values: map[string]any{
+			"value":   "sub",
+			"exports": map[string]any{"data": map[string]any{"imported": "from-sub"}},
+		},
+	}
+	parent := &chart.Chart{
+		Metadata: &chart.Metadata{
+			APIVersion: chart.APIVersionV2,
+			Name:       "parent",
+			Version:    "0.1.0",
+			Dependencies: []*chart.Dependency{{
+				Name:         "sub",
+				Version:      "0.1.0",
+				Condition:    "sub.enabled",
+				ImportValues: []any{"data"},
+			}},
+		},
+		Templates: []*chart.File{{Name: "templates/cm.yaml", Data: []byte(`apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: parent
+data:
+  imported: {{ .Values.imported | default "none" | quote }}
+`)}},
+		Values: map[string]any{
+			"sub": map[string]any{"enabled": subEnabled},
+		},
+	}
+	parent.AddDependency(sub)
+	return parent
+}
+
+func TestRenderManifestsSkipsDisabledSubcharts(t *testing.T) {
+	tests := []struct {
+		name       string
+		subEnabled bool
+		wantSub    bool
+		imported   string
+	}{
+		{name: "enabled", subEnabled: true, wantSub: true, imported: "from-sub"},
+		{name: "disabled", subEnabled: false, wantSub: false, imported: "none"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ch := testChartWithSubchart(tt.subEnabled)
+			manifests, err := renderManifests(ch, nil, defaultReleaseOptions(ch))
+			if err != nil {
+				t.Fatal(err)
+			}
+			var gotSub bool
+			var imported string
+			for _, m := range manifests {
+				switch m.source {
+				case "parent/charts/sub/templates/cm.yaml":
+					gotSub = true
+				case "parent/templates/cm.yaml":
+					imported, _, _ = unstructured.NestedString(m.obj.Object, "data", "imported")
+				}
+			}
+			if gotSub != tt.wantSub {
+				t.Errorf("subchart rendered = %v, want %v", gotSub, tt.wantSub)
+			}
+			if imported != tt.imported {
+				t.Errorf("imported value = %q, want %q", imported, tt.imported)
+			}
+			// The input chart must not be modified by the dependency processing
+			if len(ch.Dependencies()) != 1 || ch.Metadata.Dependencies[0].Enabled {
+				t.Errorf("input chart dependencies were modified")
+			}
+		})
+	}
+}
+
+func TestProcessCRDTemplates(t *testing.T) {
+	const crd = `apiVersion: apiextensions.k8s.io/v1
+kind: CustomResourceDefinition
+metadata:
+  name: foos.{{ .Values.group }}
+`
+	tests := []struct {
+		name    string
+		mode    string
+		wantErr bool
+		want    string
+		// disabled places the CRD in a subchart disabled by its condition
+		disabled bool
+	}{
+		{name: "error", mode: crdTemplatesError, wantErr: true, want: crd},
+		{name: "warn", mode: crdTemplatesWarn, want: crd},
+		{name: "render", mode: crdTemplatesRender, want: strings.ReplaceAll(crd, "{{ .Values.group }}", "example.com")},
+		{name: "render disabled subchart", mode: crdTemplatesRender, want: strings.ReplaceAll(crd, "{{ .Values.group }}", "example.com"), disabled: true},
+		{name: "unknown mode", mode: "ignore", wantErr: true, want: crd},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			crdChart := &chart.Chart{
+				Metadata: &chart.Metadata{APIVersion: chart.APIVersionV2, Name: "demo", Version: "0.1.0"},
+				Values:   map[string]any{"group": "example.com"},
+				Files:    []*chart.File{{Name: "crds/foo.yaml", Data: []byte(crd)}},
+			}
+			ch := crdChart
+			if tt.disabled {
+				crdChart.Metadata.Name = "sub"
+				ch = &chart.Chart{
+					Metadata: &chart.Metadata{
+						APIVersion:   chart.APIVersionV2,
+						Name:         "demo",
+						Version:      "0.1.0",
+						Dependencies: []*chart.Dependency{{Name: "sub", Version: "0.1.0", Condition: "sub.enabled"}},
+					},
+					Values: map[string]any{"sub": map[string]any{"enabled": false}},
+				}
+				ch.AddDependency(crdChart)
+			}
+			err := processCRDTemplates(ch, tt.mode, nil)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("processCRDTemplates() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got := string(crdChart.Files[0].Data); got != tt.want {
+				t.Errorf("CRD file = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
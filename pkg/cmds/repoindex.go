@@ -0,0 +1,124 @@
+/*
+Copyright AppsCode Inc. and Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmds
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io/fs"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Masterminds/semver/v3"
+	"helm.sh/helm/v3/pkg/chart"
+	"sigs.k8s.io/yaml"
+)
+
+// repoIndex is the index.yaml of a Helm chart repository.
+// It mirrors repo.IndexFile of the Helm SDK, which pulls in far more than we need.
+type repoIndex struct {
+	APIVersion  string                     `json:"apiVersion"`
+	Generated   time.Time                  `json:"generated"`
+	Entries     map[string][]*chartVersion `json:"entries"`
+	PublicKeys  []string                   `json:"publicKeys,omitempty"`
+	Annotations map[string]string          `json:"annotations,omitempty"`
+}
+
+// chartVersion is a single chart version entry of a repoIndex.
+type chartVersion struct {
+	*chart.Metadata
+	URLs    []string  `json:"urls"`
+	Created time.Time `json:"created,omitempty"`
+	Removed bool      `json:"removed,omitempty"`
+	Digest  string    `json:"digest,omitempty"`
+}
+
+// loadRepoIndex reads an index.yaml, returning an empty index if the file does not exist.
+func loadRepoIndex(filename string) (*repoIndex, error) {
+	index := &repoIndex{
+		APIVersion: "v1",
+		Entries:    map[string][]*chartVersion{},
+	}
+	data, err := os.ReadFile(filename)
+	if errors.Is(err, fs.ErrNotExist) {
+		return index, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if err := yaml.Unmarshal(data, index); err != nil {
+		return nil, err
+	}
+	if index.Entries == nil {
+		index.Entries = map[string][]*chartVersion{}
+	}
+	return index, nil
+}
+
+// add adds (or replaces) the entry for the chart archive with the given metadata.
+func (i *repoIndex) add(md *chart.Metadata, archive []byte, url string) {
+	sum := sha256.Sum256(archive)
+	cv := &chartVersion{
+		Metadata: md,
+		URLs:     []string{url},
+		Created:  time.Now().UTC(),
+		Digest:   hex.EncodeToString(sum[:]),
+	}
+
+	versions := i.Entries[md.Name][:0]
+	for _, v := range i.Entries[md.Name] {
+		if v.Version != md.Version {
+			versions = append(versions, v)
+		}
+	}
+	i.Entries[md.Name] = append(versions, cv)
+	i.sortEntries()
+}
+
+// sortEntries orders the versions of every chart newest first, like `helm repo index`.
+func (i *repoIndex) sortEntries() {
+	for _, versions := range i.Entries {
+		sort.SliceStable(versions, func(a, b int) bool {
+			va, errA := semver.NewVersion(versions[a].Version)
+			vb, errB := semver.NewVersion(versions[b].Version)
+			if errA != nil || errB != nil {
+				return errB != nil && errA == nil
+			}
+			return va.GreaterThan(vb)
+		})
+	}
+}
+
+// write saves the index to filename.
+func (i *repoIndex) write(filename string) error {
+	i.Generated = time.Now().UTC()
+	data, err := yaml.Marshal(i)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filename, data, 0o644)
+}
+
+// chartURL joins the base URL of a chart repository with the archive file name.
+func chartURL(baseURL, filename string) string {
+	if baseURL == "" {
+		return filename
+	}
+	return strings.TrimSuffix(baseURL, "/") + "/" + filename
+}
@@ -0,0 +1,137 @@
+/*
+Copyright AppsCode Inc. and Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmds
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"helm.sh/helm/v3/pkg/chart"
+)
+
+func TestRepoIndexAdd(t *testing.T) {
+	tests := []struct {
+		name     string
+		existing []string
+		add      string
+		want     []string
+	}{
+		{
+			name: "empty index",
+			add:  "1.0.0",
+			want: []string{"1.0.0"},
+		},
+		{
+			name:     "newest first",
+			existing: []string{"0.9.0", "1.1.0"},
+			add:      "1.0.0",
+			want:     []string{"1.1.0", "1.0.0", "0.9.0"},
+		},
+		{
+			name:     "replace existing version",
+			existing: []string{"1.0.0", "0.9.0"},
+			add:      "1.0.0",
+			want:     []string{"1.0.0", "0.9.0"},
+		},
+		{
+			name:     "prerelease",
+			existing: []string{"1.0.0"},
+			add:      "1.0.0-rc.0",
+			want:     []string{"1.0.0", "1.0.0-rc.0"},
+		},
+		{
+			name:     "invalid versions last",
+			existing: []string{"latest", "1.0.0"},
+			add:      "2.0.0",
+			want:     []string{"2.0.0", "1.0.0", "latest"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			index, err := loadRepoIndex(filepath.Join(t.TempDir(), "index.yaml"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, v := range tt.existing {
+				index.Entries["demo"] = append(index.Entries["demo"], &chartVersion{
+					Metadata: &chart.Metadata{Name: "demo", Version: v},
+					URLs:     []string{"old/demo-" + v + ".tgz"},
+				})
+			}
+
+			index.add(&chart.Metadata{Name: "demo", Version: tt.add}, []byte("archive"), "demo-"+tt.add+".tgz")
+
+			var got []string
+			for _, cv := range index.Entries["demo"] {
+				got = append(got, cv.Version)
+				if cv.Version == tt.add {
+					if !reflect.DeepEqual(cv.URLs, []string{"demo-" + tt.add + ".tgz"}) {
+						t.Errorf("urls of %s = %v, want the added archive", cv.Version, cv.URLs)
+					}
+					if sum := sha256.Sum256([]byte("archive")); cv.Digest != hex.EncodeToString(sum[:]) {
+						t.Errorf("digest of %s = %s", cv.Version, cv.Digest)
+					}
+				}
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("versions = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRepoIndexRoundTrip(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "index.yaml")
+	index, err := loadRepoIndex(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if index.APIVersion != "v1" || len(index.Entries) != 0 {
+		t.Fatalf("expected an empty v1 index for a missing file, got %+v", index)
+	}
+
+	index.add(&chart.Metadata{Name: "demo", Version: "1.0.0", APIVersion: chart.APIVersionV2}, []byte("demo"), chartURL("https://charts.example.com/", "demo-1.0.0.tgz"))
+	index.add(&chart.Metadata{Name: "other", Version: "0.1.0", APIVersion: chart.APIVersionV2}, []byte("other"), chartURL("", "other-0.1.0.tgz"))
+	if err := index.write(filename); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := loadRepoIndex(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(loaded.Entries) != 2 {
+		t.Fatalf("expected 2 charts, got %d", len(loaded.Entries))
+	}
+	if got := loaded.Entries["demo"][0].URLs; !reflect.DeepEqual(got, []string{"https://charts.example.com/demo-1.0.0.tgz"}) {
+		t.Errorf("demo urls = %v", got)
+	}
+	if got := loaded.Entries["other"][0].URLs; !reflect.DeepEqual(got, []string{"other-0.1.0.tgz"}) {
+		t.Errorf("other urls = %v", got)
+	}
+
+	if err := os.WriteFile(filename, []byte("entries: ["), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loadRepoIndex(filename); err == nil {
+		t.Error("expected an error loading an invalid index")
+	}
+}
@@ -17,25 +17,53 @@ limitations under the License.
 package cmds
 
 import (
+	"fmt"
+
 	"github.com/spf13/cobra"
 	v "gomodules.xyz/x/version"
 	cliflag "k8s.io/component-base/cli/flag"
 )
 
 func NewRootCmd() *cobra.Command {
+	var (
+		showProgress bool
+		configFile   string
+	)
 	rootCmd := &cobra.Command{
 		Use:               "chart-packer [command]",
 		Short:             `Helm chart tools by AppsCode`,
 		DisableAutoGenTag: true,
+		PersistentPreRun: func(cmd *cobra.Command, args []string) {
+			if err := applyFlagDefaults(cmd, configFile); err != nil {
+				fmt.Printf("Error: %v\n", err)
+				exit(1)
+			}
+			if showProgress {
+				enableProgress()
+			}
+			startTracing(cmd.CommandPath())
+		},
+		PersistentPostRun: func(cmd *cobra.Command, args []string) {
+			stopTracing(false)
+		},
 	}
 
 	flags := rootCmd.PersistentFlags()
 	// Normalize all flags that are coming from other packages or pre-configurations
 	// a.k.a. change all "_" to "-". e.g. glog package
 	flags.SetNormalizeFunc(cliflag.WordSepNormalizeFunc)
+	flags.StringVar(&configFile, "config", configFile, "Path to a config file providing flag defaults (defaults to $CHART_PACKER_CONFIG or "+configFileName+" in the working or home directory). Flags can also be set from CHART_PACKER_<FLAG_NAME> environment variables")
+	flags.BoolVar(&showProgress, "progress", showProgress, "If true, report the progress of each phase, with its timing, and the files written to stderr")
 
 	rootCmd.AddCommand(NewCmdGenerateCRDLessChart())
 	rootCmd.AddCommand(NewCmdGenerateCRDOnlyChart())
+	rootCmd.AddCommand(NewCmdExtract())
+	rootCmd.AddCommand(NewCmdSplitScope())
+	rootCmd.AddCommand(NewCmdInspect())
+	rootCmd.AddCommand(NewCmdValidate())
+	rootCmd.AddCommand(NewCmdE2E())
+	rootCmd.AddCommand(NewCmdSimulateUpgrade())
+	rootCmd.AddCommand(NewCmdPublish())
 	rootCmd.AddCommand(NewCmdCompletion())
 	rootCmd.AddCommand(v.NewCmdVersion())
 
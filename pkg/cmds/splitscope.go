@@ -0,0 +1,264 @@
+/*
+Copyright AppsCode Inc. and Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmds
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/chartutil"
+	crdv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/yaml"
+)
+
+// clusterScopedKinds lists the built-in cluster scoped kinds. Other kinds of built-in API groups are namespaced.
+var clusterScopedKinds = map[schema.GroupKind]bool{
+	{Kind: "Namespace"}:        true,
+	{Kind: "Node"}:             true,
+	{Kind: "PersistentVolume"}: true,
+	{Kind: "ComponentStatus"}:  true,
+	{Group: "rbac.authorization.k8s.io", Kind: "ClusterRole"}:                         true,
+	{Group: "rbac.authorization.k8s.io", Kind: "ClusterRoleBinding"}:                  true,
+	{Group: "apiextensions.k8s.io", Kind: "CustomResourceDefinition"}:                 true,
+	{Group: "apiregistration.k8s.io", Kind: "APIService"}:                             true,
+	{Group: "admissionregistration.k8s.io", Kind: "ValidatingWebhookConfiguration"}:   true,
+	{Group: "admissionregistration.k8s.io", Kind: "MutatingWebhookConfiguration"}:     true,
+	{Group: "admissionregistration.k8s.io", Kind: "ValidatingAdmissionPolicy"}:        true,
+	{Group: "admissionregistration.k8s.io", Kind: "ValidatingAdmissionPolicyBinding"}: true,
+	{Group: "admissionregistration.k8s.io", Kind: "MutatingAdmissionPolicy"}:          true,
+	{Group: "admissionregistration.k8s.io", Kind: "MutatingAdmissionPolicyBinding"}:   true,
+	{Group: "storage.k8s.io", Kind: "StorageClass"}:                                   true,
+	{Group: "storage.k8s.io", Kind: "CSIDriver"}:                                      true,
+	{Group: "storage.k8s.io", Kind: "CSINode"}:                                        true,
+	{Group: "storage.k8s.io", Kind: "VolumeAttachment"}:                               true,
+	{Group: "storage.k8s.io", Kind: "VolumeAttributesClass"}:                          true,
+	{Group: "scheduling.k8s.io", Kind: "PriorityClass"}:                               true,
+	{Group: "node.k8s.io", Kind: "RuntimeClass"}:                                      true,
+	{Group: "networking.k8s.io", Kind: "IngressClass"}:                                true,
+	{Group: "networking.k8s.io", Kind: "IPAddress"}:                                   true,
+	{Group: "networking.k8s.io", Kind: "ServiceCIDR"}:                                 true,
+	{Group: "policy", Kind: "PodSecurityPolicy"}:                                      true,
+	{Group: "certificates.k8s.io", Kind: "CertificateSigningRequest"}:                 true,
+	{Group: "certificates.k8s.io", Kind: "ClusterTrustBundle"}:                        true,
+	{Group: "flowcontrol.apiserver.k8s.io", Kind: "FlowSchema"}:                       true,
+	{Group: "flowcontrol.apiserver.k8s.io", Kind: "PriorityLevelConfiguration"}:       true,
+	{Group: "resource.k8s.io", Kind: "DeviceClass"}:                                   true,
+}
+
+// manifestsTemplate emits the rendered manifests stored under manifests/ verbatim. The manifests
+// carry the namespace they were rendered for, so installing into another namespace fails.
+const manifestsTemplate = `{{- if ne .Release.Namespace %[1]q }}
+{{- fail "this chart was rendered for namespace %[1]s by chart-packer split-scope, install it with --namespace %[1]s" }}
+{{- end }}
+{{- range $path, $_ := .Files.Glob "manifests/*.yaml" }}
+---
+{{ $.Files.Get $path }}
+{{- end }}
+`
+
+// Annotations recording the release a split-scope chart was rendered for.
+const (
+	renderNamespaceAnnotation   = "chart-packer.kmodules.xyz/render-namespace"
+	renderReleaseNameAnnotation = "chart-packer.kmodules.xyz/render-release-name"
+)
+
+func NewCmdSplitScope() *cobra.Command {
+	var (
+		input       string
+		output      string
+		releaseName string
+		namespace   = "default"
+		semver      = true
+		inOpts      = newInputOptions()
+		valOpts     valuesOptions
+	)
+	cmd := &cobra.Command{
+		Use:                   "split-scope",
+		Short:                 "Split a chart into a cluster scoped and a namespaced chart",
+		DisableFlagsInUseLine: true,
+		DisableAutoGenTag:     true,
+		Run: func(cmd *cobra.Command, args []string) {
+			vals, err := valOpts.merge()
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
+				exit(1)
+			}
+			ch, err := inOpts.load(input, false)
+			if err != nil {
+				fmt.Printf("Error loading chart: %v\n", err)
+				exit(1)
+			}
+
+			// The output charts are static, so templated CRDs are rendered as well
+			if err := processCRDTemplates(ch, crdTemplatesRender, vals); err != nil {
+				fmt.Printf("Error rendering CRD templates: %v\n", err)
+				exit(1)
+			}
+
+			options := defaultReleaseOptions(ch)
+			options.Namespace = namespace
+			if releaseName != "" {
+				options.Name = releaseName
+			}
+			fmt.Printf("Warning: the generated charts are static renders of release %s in namespace %s, values and the release name are not applied at install time\n", options.Name, options.Namespace)
+			manifests, err := renderManifests(ch, vals, options)
+			if err != nil {
+				fmt.Printf("Error rendering chart: %v\n", err)
+				exit(1)
+			}
+
+			// Scope of custom resources comes from the CRDs shipped in crds/ or rendered by templates
+			scopes := map[schema.GroupKind]crdv1.ResourceScope{}
+			for _, crd := range ch.CRDObjects() {
+				addCRDScope(scopes, crd.File.Data)
+			}
+			for _, m := range manifests {
+				if m.obj.GroupVersionKind().GroupKind() == crdv1.Kind("CustomResourceDefinition") {
+					if data, err := yaml.Marshal(m.obj.Object); err == nil {
+						addCRDScope(scopes, data)
+					}
+				}
+			}
+
+			var cluster, namespaced []renderedManifest
+			for _, m := range manifests {
+				gk := m.obj.GroupVersionKind().GroupKind()
+				scope, known := resourceScope(gk, scopes)
+				if !known {
+					fmt.Printf("Warning: unknown scope of %s/%s in %s, assuming namespaced\n", gk.Kind, gk.Group, m.source)
+				}
+				if scope == crdv1.ClusterScoped {
+					cluster = append(cluster, m)
+				} else {
+					namespaced = append(namespaced, m)
+				}
+			}
+
+			// CRDs in crds/ are cluster scoped and stay with the cluster chart
+			var crds []*chart.File
+			for _, crd := range ch.CRDObjects() {
+				rel := strings.TrimPrefix(strings.TrimPrefix(crd.Filename, ch.Name()+"/"), "crds/")
+				crds = append(crds, &chart.File{Name: "crds/" + strings.ReplaceAll(rel, "/", "_"), Data: crd.File.Data})
+			}
+
+			for _, part := range []struct {
+				suffix    string
+				scope     string
+				manifests []renderedManifest
+				crds      []*chart.File
+			}{
+				{suffix: "cluster", scope: "cluster scoped", manifests: cluster, crds: crds},
+				{suffix: "namespaced", scope: "namespaced", manifests: namespaced},
+			} {
+				newChart, err := staticChart(ch, ch.Metadata.Name+"-"+part.suffix, part.scope, options, part.manifests, part.crds)
+				if err != nil {
+					fmt.Printf("Error: %v\n", err)
+					exit(1)
+				}
+				if semver {
+					newChart.Metadata.Version = strings.TrimPrefix(ch.Metadata.Version, "v")
+				}
+				if err := saveChart(newChart, output); err != nil {
+					fmt.Printf("Error saving %s chart: %v\n", part.scope, err)
+					exit(1)
+				}
+				reportWritten(filepath.Join(output, newChart.Name()))
+				fmt.Printf("Saved %d %s resources into chart %s\n", len(part.manifests)+len(part.crds), part.scope, newChart.Name())
+			}
+		},
+	}
+
+	cmd.Flags().StringVar(&input, "input", "", "Path or URL of the input Helm chart directory or .tgz, .tar.zst or .tar file")
+	cmd.Flags().StringVar(&output, "output", "", "Output directory for the cluster scoped and namespaced charts")
+	cmd.Flags().StringVar(&releaseName, "release-name", releaseName, "Release name used to render the chart (defaults to the chart name)")
+	cmd.Flags().StringVar(&namespace, "namespace", namespace, "Namespace used to render the chart, the generated charts can only be installed into it")
+	cmd.Flags().BoolVar(&semver, "semver", semver, "If true, use strict semver version (no v prefix)")
+	inOpts.AddFlags(cmd.Flags())
+	valOpts.AddFlags(cmd.Flags())
+	_ = cobra.MarkFlagRequired(cmd.Flags(), "input")
+	_ = cmd.RegisterFlagCompletionFunc("input", completeChartInput)
+	_ = cobra.MarkFlagRequired(cmd.Flags(), "output")
+
+	return cmd
+}
+
+// addCRDScope records the scope of the custom resource defined by data, if it is a CRD.
+func addCRDScope(scopes map[schema.GroupKind]crdv1.ResourceScope, data []byte) {
+	items, err := unwrapCRDList(&chart.File{Data: data})
+	if err != nil {
+		return
+	}
+	for _, item := range items {
+		var crd crdv1.CustomResourceDefinition
+		if err := yaml.Unmarshal(item.Data, &crd); err != nil || crd.Kind != "CustomResourceDefinition" {
+			continue
+		}
+		scopes[schema.GroupKind{Group: crd.Spec.Group, Kind: crd.Spec.Names.Kind}] = crd.Spec.Scope
+	}
+}
+
+// resourceScope returns the scope of gk and whether it is known.
+func resourceScope(gk schema.GroupKind, crdScopes map[schema.GroupKind]crdv1.ResourceScope) (crdv1.ResourceScope, bool) {
+	if scope, ok := crdScopes[gk]; ok {
+		return scope, true
+	}
+	if clusterScopedKinds[gk] {
+		return crdv1.ClusterScoped, true
+	}
+	return crdv1.NamespaceScoped, isBuiltinGroup(gk.Group)
+}
+
+// staticChart builds a chart that installs the given manifests, rendered with options, as is.
+func staticChart(ch *chart.Chart, name, scope string, options chartutil.ReleaseOptions, manifests []renderedManifest, crds []*chart.File) (*chart.Chart, error) {
+	files := crds
+	for i, m := range manifests {
+		data, err := yaml.Marshal(m.obj.Object)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, &chart.File{
+			Name: fmt.Sprintf("manifests/%03d-%s-%s.yaml", i, strings.ToLower(m.obj.GetKind()), m.obj.GetName()),
+			Data: data,
+		})
+	}
+	newChart := &chart.Chart{
+		Metadata: &chart.Metadata{
+			Name:        name,
+			Version:     ch.Metadata.Version,
+			Description: "Chart containing the " + scope + " resources of " + ch.Name() + " chart",
+			APIVersion:  chart.APIVersionV2,
+			Home:        ch.Metadata.Home,
+			Sources:     ch.Metadata.Sources,
+			Keywords:    ch.Metadata.Keywords,
+			Maintainers: ch.Metadata.Maintainers,
+			Icon:        ch.Metadata.Icon,
+			AppVersion:  ch.Metadata.AppVersion,
+			Annotations: ch.Metadata.Annotations,
+			KubeVersion: ch.Metadata.KubeVersion,
+		},
+		Templates: []*chart.File{{Name: "templates/manifests.yaml", Data: []byte(fmt.Sprintf(manifestsTemplate, options.Namespace))}},
+		Files:     files,
+	}
+	renameChart(newChart, name, defaultNameAnnotations)
+	setAnnotation(newChart.Metadata, renderNamespaceAnnotation, options.Namespace)
+	setAnnotation(newChart.Metadata, renderReleaseNameAnnotation, options.Name)
+	return newChart, nil
+}
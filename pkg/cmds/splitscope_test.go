@@ -0,0 +1,58 @@
+/*
+Copyright AppsCode Inc. and Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmds
+
+import (
+	"strings"
+	"testing"
+
+	"helm.sh/helm/v3/pkg/chart"
+)
+
+func TestStaticChartRejectsOtherNamespaces(t *testing.T) {
+	ch := &chart.Chart{
+		Metadata:  &chart.Metadata{APIVersion: chart.APIVersionV2, Name: "demo", Version: "0.1.0"},
+		Templates: []*chart.File{{Name: "templates/cm.yaml", Data: []byte(configMapTemplate)}},
+		Values:    map[string]any{"value": "demo"},
+	}
+	options := defaultReleaseOptions(ch)
+	options.Namespace = "apps"
+	manifests, err := renderManifests(ch, nil, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	static, err := staticChart(ch, "demo-namespaced", "namespaced", options, manifests, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := static.Metadata.Annotations[renderNamespaceAnnotation]; got != "apps" {
+		t.Errorf("%s = %q, want apps", renderNamespaceAnnotation, got)
+	}
+
+	rendered, err := renderChart(static, nil, options)
+	if err != nil {
+		t.Fatalf("rendering in the render namespace: %v", err)
+	}
+	if out := rendered["demo-namespaced/templates/manifests.yaml"]; !strings.Contains(out, "name: demo") {
+		t.Errorf("rendered manifests = %q, want the ConfigMap demo", out)
+	}
+
+	options.Namespace = "other"
+	if _, err := renderChart(static, nil, options); err == nil || !strings.Contains(err.Error(), "--namespace apps") {
+		t.Errorf("rendering in another namespace: error = %v, want a hint to use --namespace apps", err)
+	}
+}
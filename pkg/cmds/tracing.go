@@ -0,0 +1,260 @@
+/*
+Copyright AppsCode Inc. and Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmds
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetricgrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetrichttp"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/propagation"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Spans of a command run are exported as OpenTelemetry traces, and the durations of its phases
+// as metrics. A signal is exported if OTEL_EXPORTER_OTLP_ENDPOINT or its signal specific endpoint
+// is set, and not disabled with OTEL_TRACES_EXPORTER or OTEL_METRICS_EXPORTER=none. The OTLP
+// exporters read the standard OTEL_EXPORTER_OTLP_* settings, e.g. the protocol (http/protobuf or
+// grpc), headers, timeout and certificates. The resource reads OTEL_SERVICE_NAME and
+// OTEL_RESOURCE_ATTRIBUTES, TRACEPARENT continues the trace of the calling CI job and
+// OTEL_SDK_DISABLED turns telemetry off.
+//
+// Runs that exit with an error are exported with an error status.
+const (
+	tracingScope       = "kmodules.xyz/chart-packer"
+	defaultServiceName = "chart-packer"
+)
+
+type span struct {
+	name   string
+	attrs  [][2]string
+	parent *span
+	ctx    context.Context
+	otel   trace.Span
+	start  time.Time
+	ended  bool
+}
+
+var telemetry struct {
+	tracerProvider *sdktrace.TracerProvider
+	meterProvider  *sdkmetric.MeterProvider
+	runs           metric.Int64Counter
+	phaseDuration  metric.Float64Histogram
+	root           *span
+	current        *span
+}
+
+// phaseDurationBounds are the histogram buckets of the phase durations, in seconds.
+var phaseDurationBounds = []float64{0.1, 0.5, 1, 5, 10, 30, 60, 300}
+
+// startTracing starts the root span of a command run if an OTLP endpoint is configured.
+func startTracing(name string) {
+	if v, _ := strconv.ParseBool(os.Getenv("OTEL_SDK_DISABLED")); v {
+		return
+	}
+	traces, metrics := otlpSignalEnabled("TRACES"), otlpSignalEnabled("METRICS")
+	if !traces && !metrics {
+		return
+	}
+
+	ctx := context.Background()
+	res, err := resource.New(ctx,
+		resource.WithAttributes(semconv.ServiceName(defaultServiceName)),
+		resource.WithFromEnv(),
+		resource.WithTelemetrySDK(),
+	)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: invalid telemetry resource: %v\n", err)
+	}
+	if traces {
+		if exporter, err := newOTLPTraceExporter(ctx); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to create trace exporter: %v\n", err)
+		} else {
+			telemetry.tracerProvider = sdktrace.NewTracerProvider(sdktrace.WithBatcher(exporter), sdktrace.WithResource(res))
+		}
+	}
+	if metrics {
+		if exporter, err := newOTLPMetricExporter(ctx); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to create metric exporter: %v\n", err)
+		} else {
+			telemetry.meterProvider = sdkmetric.NewMeterProvider(sdkmetric.WithReader(sdkmetric.NewPeriodicReader(exporter)), sdkmetric.WithResource(res))
+			meter := telemetry.meterProvider.Meter(tracingScope)
+			telemetry.runs, _ = meter.Int64Counter("chart_packer.runs",
+				metric.WithDescription("Runs of a command by outcome"), metric.WithUnit("{run}"))
+			telemetry.phaseDuration, _ = meter.Float64Histogram("chart_packer.phase.duration",
+				metric.WithDescription("Duration of the phases of a run"), metric.WithUnit("s"),
+				metric.WithExplicitBucketBoundaries(phaseDurationBounds...))
+		}
+	}
+	if telemetry.tracerProvider == nil && telemetry.meterProvider == nil {
+		return
+	}
+
+	// TRACEPARENT is 00-<trace id>-<parent span id>-<flags>
+	ctx = propagation.TraceContext{}.Extract(ctx, propagation.MapCarrier{"traceparent": os.Getenv("TRACEPARENT")})
+	telemetry.root = startSpanContext(ctx, name)
+}
+
+// otlpSignalEnabled reports if an OTLP endpoint is set for signal, TRACES or METRICS.
+func otlpSignalEnabled(signal string) bool {
+	if os.Getenv("OTEL_"+signal+"_EXPORTER") == "none" {
+		return false
+	}
+	return os.Getenv("OTEL_EXPORTER_OTLP_"+signal+"_ENDPOINT") != "" || os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT") != ""
+}
+
+// otlpProtocol returns the OTLP protocol of signal, http/protobuf by default.
+func otlpProtocol(signal string) string {
+	if p := os.Getenv("OTEL_EXPORTER_OTLP_" + signal + "_PROTOCOL"); p != "" {
+		return p
+	}
+	if p := os.Getenv("OTEL_EXPORTER_OTLP_PROTOCOL"); p != "" {
+		return p
+	}
+	return "http/protobuf"
+}
+
+func newOTLPTraceExporter(ctx context.Context) (sdktrace.SpanExporter, error) {
+	switch p := otlpProtocol("TRACES"); p {
+	case "http/protobuf":
+		return otlptracehttp.New(ctx)
+	case "grpc":
+		return otlptracegrpc.New(ctx)
+	default:
+		return nil, fmt.Errorf("unsupported OTLP protocol %q", p)
+	}
+}
+
+func newOTLPMetricExporter(ctx context.Context) (sdkmetric.Exporter, error) {
+	switch p := otlpProtocol("METRICS"); p {
+	case "http/protobuf":
+		return otlpmetrichttp.New(ctx)
+	case "grpc":
+		return otlpmetricgrpc.New(ctx)
+	default:
+		return nil, fmt.Errorf("unsupported OTLP protocol %q", p)
+	}
+}
+
+// stopTracing ends the root span and exports all spans and phase durations of the run.
+func stopTracing(failed bool) {
+	root := telemetry.root
+	if root == nil {
+		return
+	}
+	if failed && root.otel != nil {
+		root.otel.SetStatus(codes.Error, "run failed")
+	}
+	root.End()
+	if telemetry.runs != nil {
+		status := "ok"
+		if failed {
+			status = "error"
+		}
+		telemetry.runs.Add(root.ctx, 1, metric.WithAttributes(attribute.String("command", root.name), attribute.String("status", status)))
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if telemetry.tracerProvider != nil {
+		if err := telemetry.tracerProvider.Shutdown(ctx); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to export traces: %v\n", err)
+		}
+	}
+	if telemetry.meterProvider != nil {
+		if err := telemetry.meterProvider.Shutdown(ctx); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to export metrics: %v\n", err)
+		}
+	}
+	telemetry.tracerProvider, telemetry.meterProvider = nil, nil
+	telemetry.runs, telemetry.phaseDuration = nil, nil
+	telemetry.root = nil
+}
+
+// exit exports the telemetry of a failed run before exiting, since deferred functions and
+// PersistentPostRun do not run after os.Exit.
+func exit(code int) {
+	stopTracing(code != 0)
+	os.Exit(code)
+}
+
+// startSpan starts a child span of the current span. attrs are key, value pairs.
+// It returns nil if neither tracing nor progress is on; ending a nil span is a no-op.
+func startSpan(name string, attrs ...string) *span {
+	if telemetry.root == nil && !progress.enabled {
+		return nil
+	}
+	ctx := context.Background()
+	if telemetry.current != nil {
+		ctx = telemetry.current.ctx
+	}
+	return startSpanContext(ctx, name, attrs...)
+}
+
+func startSpanContext(ctx context.Context, name string, attrs ...string) *span {
+	s := &span{
+		name:   name,
+		parent: telemetry.current,
+		ctx:    ctx,
+		start:  time.Now(),
+	}
+	var kvs []attribute.KeyValue
+	for i := 0; i+1 < len(attrs); i += 2 {
+		s.attrs = append(s.attrs, [2]string{attrs[i], attrs[i+1]})
+		kvs = append(kvs, attribute.String(attrs[i], attrs[i+1]))
+	}
+	if telemetry.tracerProvider != nil {
+		s.ctx, s.otel = telemetry.tracerProvider.Tracer(tracingScope).Start(ctx, name, trace.WithAttributes(kvs...))
+	}
+	telemetry.current = s
+	progressf("Started %s", spanLabel(s))
+	return s
+}
+
+// End ends the span and any of its children still open.
+func (s *span) End() {
+	if s == nil || s.ended {
+		return
+	}
+	for telemetry.current != nil && telemetry.current != s {
+		telemetry.current.End()
+	}
+	s.ended = true
+	duration := time.Since(s.start)
+	if s.otel != nil {
+		s.otel.End()
+	}
+	if telemetry.phaseDuration != nil && s != telemetry.root && telemetry.root != nil {
+		telemetry.phaseDuration.Record(s.ctx, duration.Seconds(),
+			metric.WithAttributes(attribute.String("command", telemetry.root.name), attribute.String("phase", s.name)))
+	}
+	telemetry.current = s.parent
+	progressf("Finished %s in %s", spanLabel(s), duration.Round(time.Millisecond))
+}
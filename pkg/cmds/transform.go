@@ -0,0 +1,273 @@
+/*
+Copyright AppsCode Inc. and Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmds
+
+import (
+	"fmt"
+	"maps"
+	"os"
+	"path"
+	"slices"
+	"strings"
+
+	"helm.sh/helm/v3/pkg/chart"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/yaml"
+)
+
+// crdMutator modifies a decoded CRD in place and reports whether anything changed.
+type crdMutator func(key schema.GroupKind, obj map[string]any) (bool, error)
+
+// mutateCRDs applies fn to every CRD in crdMap. Modified CRDs are re-encoded into new files,
+// so the source chart stays untouched. The keys of the modified CRDs are returned.
+func mutateCRDs(crdMap map[schema.GroupKind]*chart.File, fn crdMutator) ([]schema.GroupKind, error) {
+	var modified []schema.GroupKind
+	for _, key := range sortedCRDKeys(crdMap) {
+		file := crdMap[key]
+
+		var obj map[string]any
+		if err := yaml.Unmarshal(file.Data, &obj); err != nil {
+			return nil, fmt.Errorf("failed to parse CRD %s/%s: %v", key.Kind, key.Group, err)
+		}
+		changed, err := fn(key, obj)
+		if err != nil {
+			return nil, fmt.Errorf("failed to modify CRD %s/%s: %v", key.Kind, key.Group, err)
+		}
+		if !changed {
+			continue
+		}
+		data, err := yaml.Marshal(obj)
+		if err != nil {
+			return nil, err
+		}
+		crdMap[key] = &chart.File{Name: file.Name, Data: data}
+		modified = append(modified, key)
+	}
+	return modified, nil
+}
+
+// loadShortNames reads a YAML file mapping a CRD kind to its short names.
+// Keys are either a bare kind (e.g. Postgres) or kind.group (e.g. Postgres.kubedb.com).
+func loadShortNames(filename string) (map[string][]string, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	var shortNames map[string][]string
+	if err := yaml.Unmarshal(data, &shortNames); err != nil {
+		return nil, err
+	}
+	return shortNames, nil
+}
+
+// addCRDNames returns a crdMutator that appends the given categories to every CRD
+// and the configured short names to matching CRDs.
+func addCRDNames(categories []string, shortNames map[string][]string) crdMutator {
+	return func(key schema.GroupKind, obj map[string]any) (bool, error) {
+		names, ok := shortNames[key.String()]
+		if !ok {
+			names = shortNames[key.Kind]
+		}
+
+		changedCategories, err := appendNestedStrings(obj, categories, "spec", "names", "categories")
+		if err != nil {
+			return false, err
+		}
+		changedShortNames, err := appendNestedStrings(obj, names, "spec", "names", "shortNames")
+		if err != nil {
+			return false, err
+		}
+		return changedCategories || changedShortNames, nil
+	}
+}
+
+// appendNestedStrings adds the values missing from the string slice at fields.
+func appendNestedStrings(obj map[string]any, values []string, fields ...string) (bool, error) {
+	existing, _, err := unstructured.NestedStringSlice(obj, fields...)
+	if err != nil {
+		return false, err
+	}
+	changed := false
+	for _, v := range values {
+		if !slices.Contains(existing, v) {
+			existing = append(existing, v)
+			changed = true
+		}
+	}
+	if !changed {
+		return false, nil
+	}
+	return true, unstructured.SetNestedStringSlice(obj, existing, fields...)
+}
+
+// stripSchemaExtensions returns a crdMutator that removes the named vendor extensions
+// (e.g. x-kubernetes-validations) from every schema of the CRD. The extensions actually
+// removed from each CRD are recorded in removed.
+func stripSchemaExtensions(names []string, removed map[schema.GroupKind][]string) crdMutator {
+	return func(key schema.GroupKind, obj map[string]any) (bool, error) {
+		found := map[string]bool{}
+
+		versions, _, err := unstructured.NestedSlice(obj, "spec", "versions")
+		if err != nil {
+			return false, err
+		}
+		for _, v := range versions {
+			if s, ok, _ := unstructured.NestedMap(toMap(v), "schema", "openAPIV3Schema"); ok {
+				stripSchemaNode(s, names, found)
+				_ = unstructured.SetNestedMap(toMap(v), s, "schema", "openAPIV3Schema")
+			}
+		}
+		if len(found) > 0 {
+			if err := unstructured.SetNestedSlice(obj, versions, "spec", "versions"); err != nil {
+				return false, err
+			}
+		}
+
+		// v1beta1 CRDs may carry a single top level schema
+		if s, ok, _ := unstructured.NestedMap(obj, "spec", "validation", "openAPIV3Schema"); ok {
+			before := len(found)
+			stripSchemaNode(s, names, found)
+			if len(found) > before {
+				if err := unstructured.SetNestedMap(obj, s, "spec", "validation", "openAPIV3Schema"); err != nil {
+					return false, err
+				}
+			}
+		}
+
+		if len(found) == 0 {
+			return false, nil
+		}
+		removed[key] = slices.Sorted(maps.Keys(found))
+		return true, nil
+	}
+}
+
+// stripSchemaNode deletes the named keys from schema s and all of its sub-schemas.
+func stripSchemaNode(s map[string]any, names []string, found map[string]bool) {
+	for _, name := range names {
+		if _, ok := s[name]; ok {
+			delete(s, name)
+			found[name] = true
+		}
+	}
+	for key, v := range s {
+		switch key {
+		case "properties", "patternProperties", "definitions":
+			// maps of field name to schema
+			for _, sub := range toMap(v) {
+				if m := toMap(sub); m != nil {
+					stripSchemaNode(m, names, found)
+				}
+			}
+		case "items", "additionalProperties", "additionalItems", "not", "allOf", "anyOf", "oneOf":
+			// a schema, or a list of schemas
+			if m := toMap(v); m != nil {
+				stripSchemaNode(m, names, found)
+			} else if list, ok := v.([]any); ok {
+				for _, item := range list {
+					if m := toMap(item); m != nil {
+						stripSchemaNode(m, names, found)
+					}
+				}
+			}
+		}
+	}
+}
+
+func toMap(v any) map[string]any {
+	m, _ := v.(map[string]any)
+	return m
+}
+
+// stripCRDMetadata returns a crdMutator that removes the annotations and labels
+// whose keys match any of the given glob patterns (see matchKeyAny).
+func stripCRDMetadata(annotationGlobs, labelGlobs []string) crdMutator {
+	return func(key schema.GroupKind, obj map[string]any) (bool, error) {
+		changedAnnotations, err := stripNestedKeys(obj, annotationGlobs, "metadata", "annotations")
+		if err != nil {
+			return false, err
+		}
+		changedLabels, err := stripNestedKeys(obj, labelGlobs, "metadata", "labels")
+		if err != nil {
+			return false, err
+		}
+		return changedAnnotations || changedLabels, nil
+	}
+}
+
+// stripNestedKeys removes the keys matching any of the glob patterns from the string map at fields.
+func stripNestedKeys(obj map[string]any, globs []string, fields ...string) (bool, error) {
+	if len(globs) == 0 {
+		return false, nil
+	}
+	m, found, err := unstructured.NestedStringMap(obj, fields...)
+	if err != nil || !found {
+		return false, err
+	}
+	changed := false
+	for k := range m {
+		ok, err := matchKeyAny(globs, k)
+		if err != nil {
+			return false, err
+		}
+		if ok {
+			delete(m, k)
+			changed = true
+		}
+	}
+	if !changed {
+		return false, nil
+	}
+	if len(m) == 0 {
+		unstructured.RemoveNestedField(obj, fields...)
+		return true, nil
+	}
+	return true, unstructured.SetNestedStringMap(obj, m, fields...)
+}
+
+// matchAny reports whether name matches any of the glob patterns (see path.Match).
+func matchAny(globs []string, name string) (bool, error) {
+	for _, g := range globs {
+		ok, err := path.Match(g, name)
+		if err != nil {
+			return false, fmt.Errorf("invalid pattern %q: %v", g, err)
+		}
+		if ok {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// matchKeyAny reports whether an annotation or label key matches any of the glob patterns.
+// The patterns use the path.Match syntax, except that * and ? also match the / separating the
+// prefix of a key, so * and *version both match controller-gen.kubebuilder.io/version.
+func matchKeyAny(globs []string, key string) (bool, error) {
+	// keys never contain NUL, so hiding the separator from path.Match keeps the rest of its syntax
+	const sep = "\x00"
+	for _, g := range globs {
+		ok, err := path.Match(strings.ReplaceAll(g, "/", sep), strings.ReplaceAll(key, "/", sep))
+		if err != nil {
+			return false, fmt.Errorf("invalid pattern %q: %v", g, err)
+		}
+		if ok {
+			return true, nil
+		}
+	}
+	return false, nil
+}
@@ -0,0 +1,49 @@
+/*
+Copyright AppsCode Inc. and Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmds
+
+import "testing"
+
+func TestMatchKeyAny(t *testing.T) {
+	const key = "controller-gen.kubebuilder.io/version"
+	tests := []struct {
+		glob    string
+		want    bool
+		wantErr bool
+	}{
+		{glob: "*", want: true},
+		{glob: "*version", want: true},
+		{glob: "controller-gen.kubebuilder.io/*", want: true},
+		{glob: "*.kubebuilder.io/*", want: true},
+		{glob: "controller-gen.kubebuilder.io/ver?ion", want: true},
+		{glob: "controller-gen.kubebuilder.io/[uv]ersion", want: true},
+		{glob: "*/name", want: false},
+		{glob: "version", want: false},
+		{glob: "[", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.glob, func(t *testing.T) {
+			got, err := matchKeyAny([]string{tt.glob}, key)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("matchKeyAny() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("matchKeyAny(%q, %q) = %v, want %v", tt.glob, key, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,209 @@
+/*
+Copyright AppsCode Inc. and Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmds
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/spf13/cobra"
+	"helm.sh/helm/v3/pkg/chart"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/rest"
+)
+
+const simulateWithNone = "none"
+
+func NewCmdSimulateUpgrade() *cobra.Command {
+	var (
+		from         string
+		to           string
+		simulateWith = "envtest"
+		inOpts       = newInputOptions()
+		envOpts      = newEnvtestOptions()
+	)
+	cmd := &cobra.Command{
+		Use:                   "simulate-upgrade",
+		Short:                 "Upgrade the CRDs of a previous crd-only chart to a new one and report what breaks",
+		Long:                  "Applies the CRDs of the previous crd-only chart to a throwaway cluster, then applies the new ones on top, the way kubectl apply or the --installer-job chart upgrade them (helm never upgrades crds/), and reports removed CRDs and versions, storage version migrations and update conflicts.",
+		DisableFlagsInUseLine: true,
+		DisableAutoGenTag:     true,
+		Run: func(cmd *cobra.Command, args []string) {
+			switch simulateWith {
+			case "envtest", clusterProviderKind, clusterProviderK3d, simulateWithNone:
+			default:
+				fmt.Printf("Error: unsupported --simulate-with %q, use envtest, kind, k3d or none\n", simulateWith)
+				exit(1)
+			}
+			oldMap, err := loadChartCRDs(inOpts, from)
+			if err != nil {
+				fmt.Printf("Error loading previous chart: %v\n", err)
+				exit(1)
+			}
+			// The verification flags apply to the released chart, the new one is generated locally
+			newMap, err := loadChartCRDs(newInputOptions(), to)
+			if err != nil {
+				fmt.Printf("Error loading new chart: %v\n", err)
+				exit(1)
+			}
+
+			results, err := crdUpgradeResults(oldMap, newMap)
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
+				exit(1)
+			}
+			if simulateWith != simulateWithNone {
+				s := startSpan("simulate-upgrade", "cluster", simulateWith)
+				results = append(results, simulateCRDUpgrade(simulateWith, envOpts, oldMap, newMap)...)
+				s.End()
+			}
+
+			fmt.Printf("Simulated upgrade of %d CRDs to %d CRDs\n", len(oldMap), len(newMap))
+			if failed := printValidationResults(results); failed > 0 {
+				fmt.Printf("%d of %d checks failed\n", failed, len(results))
+				exit(1)
+			}
+		},
+	}
+
+	cmd.Flags().StringVar(&from, "from", from, "Path or URL of the previously released crd-only chart directory or .tgz, .tar.zst or .tar file")
+	cmd.Flags().StringVar(&to, "to", to, "Path or URL of the newly generated crd-only chart directory or .tgz, .tar.zst or .tar file")
+	cmd.Flags().StringVar(&simulateWith, "simulate-with", simulateWith, "Cluster to upgrade the CRDs in: envtest, kind, k3d or none to only compare the CRDs")
+	inOpts.AddFlags(cmd.Flags())
+	envOpts.AddFlags(cmd.Flags())
+	_ = cobra.MarkFlagRequired(cmd.Flags(), "from")
+	_ = cobra.MarkFlagRequired(cmd.Flags(), "to")
+	_ = cmd.RegisterFlagCompletionFunc("from", completeChartInput)
+	_ = cmd.RegisterFlagCompletionFunc("to", completeChartInput)
+
+	return cmd
+}
+
+func loadChartCRDs(inOpts inputOptions, input string) (map[schema.GroupKind]*chart.File, error) {
+	ch, err := inOpts.load(input, false)
+	if err != nil {
+		return nil, err
+	}
+	crdMap := map[schema.GroupKind]*chart.File{}
+	if err := collectCRDs(os.Stderr, ch, ch.Name(), nil, crdMap, map[schema.GroupKind]string{}); err != nil {
+		return nil, err
+	}
+	return crdMap, nil
+}
+
+// crdUpgradeResults compares the CRDs of the previous and the new chart.
+func crdUpgradeResults(oldMap, newMap map[schema.GroupKind]*chart.File) ([]validationResult, error) {
+	var removed, removedVersions, scopeChanges, migrations, blocked []string
+	for _, key := range sortedCRDKeys(oldMap) {
+		name := key.Kind + "/" + key.Group
+		if _, ok := newMap[key]; !ok {
+			removed = append(removed, name)
+			continue
+		}
+		oldCRD, err := decodeCRD(oldMap[key].Data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse previous CRD %s: %v", name, err)
+		}
+		newCRD, err := decodeCRD(newMap[key].Data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse new CRD %s: %v", name, err)
+		}
+
+		if oldCRD.Spec.Scope != newCRD.Spec.Scope {
+			scopeChanges = append(scopeChanges, fmt.Sprintf("%s: scope changes from %s to %s", name, oldCRD.Spec.Scope, newCRD.Spec.Scope))
+		}
+		newVersions := map[string]bool{}
+		var newStorage string
+		for _, v := range newCRD.Spec.Versions {
+			newVersions[v.Name] = true
+			if v.Storage {
+				newStorage = v.Name
+			}
+		}
+		for _, v := range oldCRD.Spec.Versions {
+			if v.Served && !newVersions[v.Name] {
+				removedVersions = append(removedVersions, fmt.Sprintf("%s: served version %s is removed", name, v.Name))
+			}
+			if !v.Storage || v.Name == newStorage {
+				continue
+			}
+			if newVersions[v.Name] {
+				migrations = append(migrations, fmt.Sprintf("%s: storage version changes from %s to %s, migrate the stored objects before removing %s", name, v.Name, newStorage, v.Name))
+			} else {
+				blocked = append(blocked, fmt.Sprintf("%s: previous storage version %s is removed, the upgrade is rejected until the stored objects are migrated and %s is dropped from status.storedVersions", name, v.Name, v.Name))
+			}
+		}
+	}
+	return []validationResult{
+		{check: "no CRDs removed", failed: len(removed) > 0, details: removed},
+		{check: "no served versions removed", failed: len(removedVersions) > 0, details: removedVersions},
+		{check: "scope unchanged", failed: len(scopeChanges) > 0, details: scopeChanges},
+		{check: "storage version migrations", failed: len(blocked) > 0, warning: len(migrations) > 0, details: append(blocked, migrations...)},
+	}, nil
+}
+
+// simulateCRDUpgrade applies the previous CRDs, then the new ones to a throwaway cluster.
+func simulateCRDUpgrade(simulateWith string, envOpts envtestOptions, oldMap, newMap map[schema.GroupKind]*chart.File) []validationResult {
+	failed := func(check string, err error) []validationResult {
+		return []validationResult{{check: check, failed: true, details: []string{err.Error()}}}
+	}
+
+	var config *rest.Config
+	switch simulateWith {
+	case clusterProviderKind, clusterProviderK3d:
+		dir, err := os.MkdirTemp("", "chart-packer-upgrade-")
+		if err != nil {
+			return failed("start cluster", err)
+		}
+		defer func() { _ = os.RemoveAll(dir) }()
+		kubeconfig := filepath.Join(dir, "kubeconfig")
+		name := fmt.Sprintf("chart-packer-upgrade-%d", time.Now().Unix())
+		if err := createE2ECluster(simulateWith, name, "", kubeconfig); err != nil {
+			return failed("start cluster", err)
+		}
+		defer func() { _ = deleteE2ECluster(simulateWith, name, kubeconfig) }()
+		opts := clusterOptions{kubeconfig: kubeconfig}
+		if config, err = opts.restConfig(); err != nil {
+			return failed("start cluster", err)
+		}
+	default:
+		cp, err := envOpts.start()
+		if err != nil {
+			return failed("start cluster", err)
+		}
+		defer cp.stop()
+		config = cp.config
+	}
+
+	rejected, err := applyCRDs(config, oldMap, false)
+	if err != nil {
+		return failed("install previous CRDs", err)
+	}
+	if len(rejected) > 0 {
+		return []validationResult{{check: "install previous CRDs", failed: true, details: rejected}}
+	}
+	rejected, err = applyCRDs(config, newMap, false)
+	if err != nil {
+		return failed("upgrade to new CRDs", err)
+	}
+	return []validationResult{
+		{check: "install previous CRDs"},
+		{check: "upgrade to new CRDs", failed: len(rejected) > 0, details: rejected},
+	}
+}
@@ -0,0 +1,173 @@
+/*
+Copyright AppsCode Inc. and Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmds
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"helm.sh/helm/v3/pkg/chart"
+	crdv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	crdv1beta1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/yaml"
+)
+
+// crdAPIVersions lists the known CRD apiVersions, oldest first.
+var crdAPIVersions = []string{
+	crdv1beta1.SchemeGroupVersion.Version,
+	crdv1.SchemeGroupVersion.Version,
+}
+
+// crdAPIVersion returns the version part of the apiVersion of a CRD manifest, e.g. v1beta1.
+func crdAPIVersion(data []byte) (string, error) {
+	var tm metav1.TypeMeta
+	if err := yaml.Unmarshal(data, &tm); err != nil {
+		return "", err
+	}
+	gv, err := schema.ParseGroupVersion(tm.APIVersion)
+	if err != nil {
+		return "", err
+	}
+	return gv.Version, nil
+}
+
+// reportCRDAPIVersions prints the number of CRDs per apiVersion and lists the deprecated ones.
+func reportCRDAPIVersions(crdMap map[schema.GroupKind]*chart.File) {
+	byVersion := map[string][]string{}
+	for _, key := range sortedCRDKeys(crdMap) {
+		v, err := crdAPIVersion(crdMap[key].Data)
+		if err != nil {
+			v = "unknown"
+		}
+		byVersion[v] = append(byVersion[v], key.Kind+"/"+key.Group)
+	}
+	for _, v := range slices.Sorted(maps.Keys(byVersion)) {
+		fmt.Printf("CRDs using apiextensions.k8s.io/%s: %d\n", v, len(byVersion[v]))
+		if v != crdv1.SchemeGroupVersion.Version {
+			for _, name := range byVersion[v] {
+				fmt.Printf("  - %s (deprecated)\n", name)
+			}
+		}
+	}
+}
+
+// checkMinCRDAPIVersion returns an error listing every CRD whose apiVersion is older than minVersion.
+func checkMinCRDAPIVersion(crdMap map[schema.GroupKind]*chart.File, minVersion string) error {
+	minVersion = strings.TrimPrefix(minVersion, crdv1.GroupName+"/")
+	minIdx := slices.Index(crdAPIVersions, minVersion)
+	if minIdx < 0 {
+		return fmt.Errorf("unknown CRD apiVersion %q, must be one of %s", minVersion, strings.Join(crdAPIVersions, ", "))
+	}
+
+	var offenders []string
+	for _, key := range sortedCRDKeys(crdMap) {
+		v, err := crdAPIVersion(crdMap[key].Data)
+		if err != nil {
+			return fmt.Errorf("failed to parse CRD %s/%s: %v", key.Kind, key.Group, err)
+		}
+		if idx := slices.Index(crdAPIVersions, v); idx < minIdx {
+			offenders = append(offenders, fmt.Sprintf("%s/%s (%s)", key.Kind, key.Group, v))
+		}
+	}
+	if len(offenders) > 0 {
+		return fmt.Errorf("CRDs older than apiextensions.k8s.io/%s: %s", minVersion, strings.Join(offenders, ", "))
+	}
+	return nil
+}
+
+// crdsWithoutPrinterColumns lists the served CRD versions that define no additionalPrinterColumns.
+func crdsWithoutPrinterColumns(crdMap map[schema.GroupKind]*chart.File) ([]string, error) {
+	return servedVersionsWhere(crdMap, func(v crdv1.CustomResourceDefinitionVersion) bool {
+		return len(v.AdditionalPrinterColumns) == 0
+	})
+}
+
+// crdsWithoutStatusSubresource lists the served CRD versions that do not enable the status subresource.
+func crdsWithoutStatusSubresource(crdMap map[schema.GroupKind]*chart.File) ([]string, error) {
+	return servedVersionsWhere(crdMap, func(v crdv1.CustomResourceDefinitionVersion) bool {
+		return v.Subresources == nil || v.Subresources.Status == nil
+	})
+}
+
+// servedVersionsWhere returns one entry per CRD listing its served versions matching fn,
+// formatted as "Kind/group (v1, v2)".
+func servedVersionsWhere(crdMap map[schema.GroupKind]*chart.File, fn func(v crdv1.CustomResourceDefinitionVersion) bool) ([]string, error) {
+	var result []string
+	for _, key := range sortedCRDKeys(crdMap) {
+		crd, err := decodeCRD(crdMap[key].Data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse CRD %s/%s: %v", key.Kind, key.Group, err)
+		}
+		var versions []string
+		for _, v := range crd.Spec.Versions {
+			if v.Served && fn(v) {
+				versions = append(versions, v.Name)
+			}
+		}
+		if len(versions) > 0 {
+			result = append(result, fmt.Sprintf("%s/%s (%s)", key.Kind, key.Group, strings.Join(versions, ", ")))
+		}
+	}
+	return result, nil
+}
+
+// crdsLargerThan lists the CRDs whose manifest exceeds limit bytes.
+func crdsLargerThan(crdMap map[schema.GroupKind]*chart.File, limit int) []string {
+	var result []string
+	for _, key := range sortedCRDKeys(crdMap) {
+		if size := len(crdMap[key].Data); size > limit {
+			result = append(result, fmt.Sprintf("%s/%s (%s)", key.Kind, key.Group, formatBytes(int64(size))))
+		}
+	}
+	return result
+}
+
+// missingMetadataFields lists the fields, named as in Chart.yaml, that are empty in md.
+func missingMetadataFields(md *chart.Metadata, fields []string) ([]string, error) {
+	var missing []string
+	for _, field := range fields {
+		var empty bool
+		switch field {
+		case "description":
+			empty = md.Description == ""
+		case "home":
+			empty = md.Home == ""
+		case "icon":
+			empty = md.Icon == ""
+		case "sources":
+			empty = len(md.Sources) == 0
+		case "keywords":
+			empty = len(md.Keywords) == 0
+		case "maintainers":
+			empty = len(md.Maintainers) == 0
+		case "appVersion":
+			empty = md.AppVersion == ""
+		case "kubeVersion":
+			empty = md.KubeVersion == ""
+		default:
+			return nil, fmt.Errorf("unsupported metadata field %q", field)
+		}
+		if empty {
+			missing = append(missing, field)
+		}
+	}
+	return missing, nil
+}
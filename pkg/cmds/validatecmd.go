@@ -0,0 +1,163 @@
+/*
+Copyright AppsCode Inc. and Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmds
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/chartutil"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// defaultMaxCRDSize is the default request size limit of etcd, which no CRD can exceed.
+const defaultMaxCRDSize = 3 * 512 * 1024
+
+// validationResult is the outcome of a single check run by the validate command.
+type validationResult struct {
+	check   string
+	failed  bool
+	warning bool
+	details []string
+}
+
+func NewCmdValidate() *cobra.Command {
+	var (
+		input        string
+		renderCRDs   bool
+		crdTemplates = crdTemplatesWarn
+		minCRDAPI    string
+		reqStatus    bool
+		valDefaults  = true
+		maxCRDSize   = defaultMaxCRDSize
+		reqFields    []string
+		inOpts       = newInputOptions()
+		valOpts      valuesOptions
+	)
+	cmd := &cobra.Command{
+		Use:                   "validate",
+		Short:                 "Run the chart-packer checks against a chart without repackaging it",
+		DisableFlagsInUseLine: true,
+		DisableAutoGenTag:     true,
+		Run: func(cmd *cobra.Command, args []string) {
+			if renderCRDs && !cmd.Flags().Changed("crd-templates") {
+				crdTemplates = crdTemplatesRender
+			}
+			vals, err := valOpts.merge()
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
+				exit(1)
+			}
+			ch, err := inOpts.load(input, false)
+			if err != nil {
+				fmt.Printf("Error loading chart: %v\n", err)
+				exit(1)
+			}
+			if err := processCRDTemplates(ch, crdTemplates, vals); err != nil {
+				fmt.Printf("Error rendering CRD templates: %v\n", err)
+				exit(1)
+			}
+			crdMap := map[schema.GroupKind]*chart.File{}
+			if err := collectCRDs(os.Stdout, ch, ch.Name(), nil, crdMap, map[schema.GroupKind]string{}); err != nil {
+				fmt.Printf("Error: %v\n", err)
+				exit(1)
+			}
+
+			var results []validationResult
+			check := func(name string, details []string, err error) {
+				r := validationResult{check: name, failed: err != nil || len(details) > 0, details: details}
+				if err != nil {
+					r.details = append(r.details, err.Error())
+				}
+				results = append(results, r)
+			}
+
+			check("chart metadata", nil, ch.Validate())
+			if len(reqFields) > 0 {
+				missing, err := missingMetadataFields(ch.Metadata, reqFields)
+				check("required metadata fields", missing, err)
+			}
+			top, err := chartutil.CoalesceValues(ch, vals)
+			if err == nil {
+				err = chartutil.ValidateAgainstSchema(ch, top)
+			}
+			check("values schema conformance", nil, err)
+
+			if minCRDAPI != "" {
+				check("minimum CRD apiVersion", nil, checkMinCRDAPIVersion(crdMap, minCRDAPI))
+			}
+			if reqStatus {
+				missing, err := crdsWithoutStatusSubresource(crdMap)
+				check("status subresource", missing, err)
+			}
+			if valDefaults {
+				invalid, err := invalidSchemaDefaults(crdMap)
+				check("CRD schema defaults", invalid, err)
+			}
+			check("CRD size", crdsLargerThan(crdMap, maxCRDSize), nil)
+			if missing, err := crdsWithoutPrinterColumns(crdMap); err == nil {
+				results = append(results, validationResult{check: "additionalPrinterColumns", warning: len(missing) > 0, details: missing})
+			}
+
+			fmt.Printf("Validated chart %s %s with %d CRDs\n", ch.Name(), ch.Metadata.Version, len(crdMap))
+			if failed := printValidationResults(results); failed > 0 {
+				fmt.Printf("%d of %d checks failed\n", failed, len(results))
+				exit(1)
+			}
+		},
+	}
+
+	cmd.Flags().StringVar(&input, "input", "", "Path or URL of the Helm chart directory or .tgz, .tar.zst or .tar file to validate")
+	cmd.Flags().StringVar(&crdTemplates, "crd-templates", crdTemplates, "What to do with template expressions found in crds/ files, which Helm does not render: error, warn or render them using the chart values and --values/--set")
+	cmd.Flags().BoolVar(&renderCRDs, "render-crds", renderCRDs, "If true, render template expressions found in crds/ files using the chart values and --values/--set")
+	_ = cmd.Flags().MarkDeprecated("render-crds", "use --crd-templates=render instead")
+	cmd.Flags().StringVar(&minCRDAPI, "min-crd-api", minCRDAPI, "Fail if any CRD uses an apiextensions.k8s.io version older than this (v1beta1 or v1)")
+	cmd.Flags().BoolVar(&reqStatus, "require-status-subresource", reqStatus, "If true, fail if any served CRD version does not enable the status subresource")
+	cmd.Flags().BoolVar(&valDefaults, "validate-defaults", valDefaults, "If true, fail if a default in a CRD schema does not validate against its own schema")
+	cmd.Flags().IntVar(&maxCRDSize, "max-crd-size", maxCRDSize, "Maximum size in bytes of a CRD manifest, defaults to the etcd request size limit")
+	cmd.Flags().StringSliceVar(&reqFields, "require-metadata", reqFields, "Chart.yaml field that must be set, e.g. home, sources, icon, maintainers (repeatable)")
+	inOpts.AddFlags(cmd.Flags())
+	valOpts.AddFlags(cmd.Flags())
+	_ = cobra.MarkFlagRequired(cmd.Flags(), "input")
+	_ = cmd.RegisterFlagCompletionFunc("input", completeChartInput)
+
+	return cmd
+}
+
+// printValidationResults prints one PASS, FAIL or WARN line per result, followed by its details,
+// and returns the number of failed checks.
+func printValidationResults(results []validationResult) int {
+	failed := 0
+	for _, r := range results {
+		status := "PASS"
+		switch {
+		case r.failed:
+			status = "FAIL"
+			failed++
+		case r.warning:
+			status = "WARN"
+		}
+		fmt.Printf("%s  %s\n", status, r.check)
+		for _, d := range r.details {
+			fmt.Printf("        - %s\n", strings.ReplaceAll(strings.TrimSpace(d), "\n", "\n          "))
+		}
+	}
+	return failed
+}
@@ -0,0 +1,90 @@
+/*
+Copyright AppsCode Inc. and Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmds
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/pflag"
+	"helm.sh/helm/v3/pkg/strvals"
+	"sigs.k8s.io/yaml"
+)
+
+// valuesOptions holds user supplied values, used wherever the chart needs to be evaluated
+// (rendering templated CRDs, dependency conditions), like `helm install -f --set`.
+type valuesOptions struct {
+	valueFiles []string
+	values     []string
+}
+
+func (o *valuesOptions) AddFlags(fs *pflag.FlagSet) {
+	fs.StringSliceVarP(&o.valueFiles, "values", "f", o.valueFiles, "Specify values in a YAML file or a URL (can specify multiple)")
+	fs.StringArrayVar(&o.values, "set", o.values, "Set values on the command line (can specify multiple or separate values with commas: key1=val1,key2=val2)")
+}
+
+// merge reads the value files in order and applies the --set values on top.
+func (o *valuesOptions) merge() (map[string]any, error) {
+	base := map[string]any{}
+	for _, file := range o.valueFiles {
+		var data []byte
+		var err error
+		if isURL(file) {
+			data, err = downloadFile(file)
+			if err == nil && data == nil {
+				err = fmt.Errorf("not found")
+			}
+		} else {
+			data, err = os.ReadFile(file)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read values file %s: %v", file, err)
+		}
+
+		current := map[string]any{}
+		if err := yaml.Unmarshal(data, &current); err != nil {
+			return nil, fmt.Errorf("failed to parse values file %s: %v", file, err)
+		}
+		base = mergeMaps(base, current)
+	}
+	for _, value := range o.values {
+		if err := strvals.ParseInto(value, base); err != nil {
+			return nil, fmt.Errorf("failed parsing --set data: %v", err)
+		}
+	}
+	return base, nil
+}
+
+// mergeMaps deep merges b into a copy of a, the same way Helm merges value files.
+func mergeMaps(a, b map[string]any) map[string]any {
+	out := make(map[string]any, len(a))
+	for k, v := range a {
+		out[k] = v
+	}
+	for k, v := range b {
+		if v, ok := v.(map[string]any); ok {
+			if bv, ok := out[k]; ok {
+				if bv, ok := bv.(map[string]any); ok {
+					out[k] = mergeMaps(bv, v)
+					continue
+				}
+			}
+		}
+		out[k] = v
+	}
+	return out
+}
@@ -0,0 +1,124 @@
+/*
+Copyright AppsCode Inc. and Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmds
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/chartutil"
+	crdv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/yaml"
+)
+
+// renderedManifest is a single YAML document produced by rendering a chart template.
+type renderedManifest struct {
+	source string
+	obj    *unstructured.Unstructured
+}
+
+var manifestSeparator = regexp.MustCompile(`(?m)^---\s*$`)
+
+// renderManifests renders the templates of ch and its subcharts and splits the output into
+// Kubernetes objects, ordered by source template. Documents without apiVersion and kind are skipped.
+func renderManifests(ch *chart.Chart, vals map[string]any, options chartutil.ReleaseOptions) ([]renderedManifest, error) {
+	rendered, err := renderChart(ch, vals, options)
+	if err != nil {
+		return nil, err
+	}
+	sources := make([]string, 0, len(rendered))
+	for name := range rendered {
+		if ext := path.Ext(name); ext == ".yaml" || ext == ".yml" || ext == ".json" {
+			sources = append(sources, name)
+		}
+	}
+	sort.Strings(sources)
+
+	var result []renderedManifest
+	for _, name := range sources {
+		for _, doc := range manifestSeparator.Split(rendered[name], -1) {
+			if strings.TrimSpace(doc) == "" {
+				continue
+			}
+			var obj map[string]any
+			if err := yaml.Unmarshal([]byte(doc), &obj); err != nil {
+				return nil, fmt.Errorf("failed to parse rendered template %s: %v", name, err)
+			}
+			u := &unstructured.Unstructured{Object: obj}
+			if u.GetAPIVersion() == "" || u.GetKind() == "" {
+				continue
+			}
+			result = append(result, renderedManifest{source: name, obj: u})
+		}
+	}
+	return result, nil
+}
+
+// crdGroupKinds returns the group and kind of every parsable CRD of ch and its subcharts.
+// CRD files with template expressions are rendered with vals first.
+func crdGroupKinds(ch *chart.Chart, vals map[string]any) map[schema.GroupKind]bool {
+	var rendered map[string]string
+	if len(templatedCRDs(ch)) > 0 {
+		rendered, _ = renderCRDFiles(ch, vals)
+	}
+	result := map[schema.GroupKind]bool{}
+	for _, crd := range ch.CRDObjects() {
+		data := crd.File.Data
+		if out, ok := rendered[crd.Filename]; ok {
+			data = []byte(out)
+		}
+		items, err := unwrapCRDList(&chart.File{Name: crd.Filename, Data: data})
+		if err != nil {
+			continue
+		}
+		for _, item := range items {
+			if key, err := extractCRDKey(item.Data); err == nil {
+				result[*key] = true
+			}
+		}
+	}
+	return result
+}
+
+// customResourceRefs lists the rendered objects whose kind is defined by one of the given CRDs.
+func customResourceRefs(manifests []renderedManifest, crds map[schema.GroupKind]bool) []string {
+	var refs []string
+	for _, m := range manifests {
+		gk := m.obj.GroupVersionKind().GroupKind()
+		if crds[gk] {
+			refs = append(refs, fmt.Sprintf("%s creates %s/%s %q", m.source, gk.Kind, gk.Group, m.obj.GetName()))
+		}
+	}
+	return refs
+}
+
+// templateCRDs lists the CustomResourceDefinitions produced by rendered templates.
+func templateCRDs(manifests []renderedManifest) []string {
+	var result []string
+	for _, m := range manifests {
+		if m.obj.GroupVersionKind().GroupKind() == crdv1.Kind("CustomResourceDefinition") {
+			result = append(result, fmt.Sprintf("%s renders CustomResourceDefinition %s", m.source, m.obj.GetName()))
+		}
+	}
+	return result
+}